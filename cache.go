@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/campbel/run/loader"
+	"github.com/pkg/errors"
+)
+
+type CacheCommands struct {
+	Ls    func(CacheOptions) error
+	Prune func(PruneOptions) error
+	Clear func(CacheOptions) error
+}
+
+type CacheOptions struct{}
+
+type PruneOptions struct {
+	OlderThan string `yoshi:"--older-than;Remove packages not used for this long, e.g. 12h or 30d;30d"`
+}
+
+var cacheCommands = CacheCommands{
+	Ls: func(CacheOptions) error {
+		cache, err := loader.DefaultCache()
+		if err != nil {
+			return err
+		}
+		return listCache(os.Stdout, cache)
+	},
+	Prune: func(options PruneOptions) error {
+		age, err := parseAge(options.OlderThan)
+		if err != nil {
+			return err
+		}
+		cache, err := loader.DefaultCache()
+		if err != nil {
+			return err
+		}
+		return pruneCache(os.Stdout, cache, time.Now().Add(-age))
+	},
+	Clear: func(CacheOptions) error {
+		cache, err := loader.DefaultCache()
+		if err != nil {
+			return err
+		}
+		return cache.Clear()
+	},
+}
+
+// listCache writes a row for every source of every cached package.
+func listCache(w io.Writer, cache *loader.Cache) error {
+	entries, err := cache.List()
+	if err != nil {
+		return err
+	}
+	tabwriter := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(tabwriter, "HASH\tSOURCE\tREF\tUSED")
+	for _, entry := range entries {
+		for _, source := range entry.Sources {
+			fmt.Fprintf(tabwriter, "%s\t%s\t%s\t%s\n", shortHash(entry.Hash), source.Source, source.Ref, entry.Used.Format(time.RFC3339))
+		}
+	}
+	return tabwriter.Flush()
+}
+
+// pruneCache removes the packages not used since before and writes what was
+// removed.
+func pruneCache(w io.Writer, cache *loader.Cache, before time.Time) error {
+	pruned, err := cache.Prune(before)
+	for _, entry := range pruned {
+		for _, source := range entry.Sources {
+			fmt.Fprintln(w, "removed", shortHash(entry.Hash), source.Source)
+		}
+	}
+	return err
+}
+
+// parseAge parses a duration that may also be given in days, e.g. 30d.
+func parseAge(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, errors.Errorf("invalid age '%s'", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	age, err := time.ParseDuration(value)
+	return age, errors.Wrapf(err, "invalid age '%s'", value)
+}
+
+func shortHash(hash string) string {
+	hash = strings.TrimPrefix(hash, "sha256:")
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/campbel/run/loader"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseAge(t *testing.T) {
+	tests := map[string]time.Duration{
+		"30d":   30 * 24 * time.Hour,
+		"0d":    0,
+		"12h":   12 * time.Hour,
+		"1h30m": 90 * time.Minute,
+	}
+	for value, expected := range tests {
+		age, err := parseAge(value)
+		assert.NoError(t, err, value)
+		assert.Equal(t, expected, age, value)
+	}
+
+	for _, value := range []string{"", "d", "1.5d", "30 days", "forever"} {
+		_, err := parseAge(value)
+		assert.ErrorContains(t, err, "invalid age", value)
+	}
+}
+
+func TestCacheCommands(t *testing.T) {
+	cache := loader.NewCache(t.TempDir())
+	get := func(dst string) error {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, "run.yaml"), []byte("actions: {}"), 0644)
+	}
+	entry, err := cache.Fetch("github.com/org/pkg", "abc", get)
+	assert.NoError(t, err)
+	_, err = cache.Fetch("github.com/fork/pkg", "def", get)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, listCache(&out, cache))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 3)
+	assert.Equal(t, []string{"HASH", "SOURCE", "REF", "USED"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{shortHash(entry.Hash), "github.com/org/pkg", "abc"}, strings.Fields(lines[1])[:3])
+	assert.Equal(t, []string{shortHash(entry.Hash), "github.com/fork/pkg", "def"}, strings.Fields(lines[2])[:3])
+
+	out.Reset()
+	assert.NoError(t, pruneCache(&out, cache, time.Now().Add(-time.Hour)))
+	assert.Empty(t, out.String())
+
+	assert.NoError(t, pruneCache(&out, cache, time.Now().Add(time.Hour)))
+	assert.Equal(t, ""+
+		"removed "+shortHash(entry.Hash)+" github.com/org/pkg\n"+
+		"removed "+shortHash(entry.Hash)+" github.com/fork/pkg\n",
+		out.String())
+
+	out.Reset()
+	assert.NoError(t, listCache(&out, cache))
+	assert.Equal(t, "HASH SOURCE REF USED\n", out.String())
+}
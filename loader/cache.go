@@ -0,0 +1,231 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Cache is a content addressed store of fetched packages shared by every
+// project of the user. Projects copy packages out of it, so commands writing
+// to their package directory never change the cached content.
+type Cache struct {
+	dir string
+	// mu guards the entries against concurrent fetches of the same content
+	mu sync.Mutex
+}
+
+// CacheEntry describes a cached package, it is stored next to its content.
+// Every source and ref the content was fetched from is kept, as identical
+// content is stored once.
+type CacheEntry struct {
+	Hash    string        `yaml:"hash"`
+	Sources []CacheSource `yaml:"sources"`
+	Fetched time.Time     `yaml:"fetched"`
+	Used    time.Time     `yaml:"used"`
+}
+
+type CacheSource struct {
+	Source string `yaml:"source"`
+	Ref    string `yaml:"ref,omitempty"`
+}
+
+func (e CacheEntry) has(source, ref string) bool {
+	for _, s := range e.Sources {
+		if s.Source == source && s.Ref == ref {
+			return true
+		}
+	}
+	return false
+}
+
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// DefaultCache returns the cache in the user cache directory, e.g.
+// $XDG_CACHE_HOME/run.
+func DefaultCache() (*Cache, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find user cache directory")
+	}
+	return NewCache(filepath.Join(dir, "run")), nil
+}
+
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Lookup returns the package fetched from source at ref.
+func (c *Cache) Lookup(source, ref string) (CacheEntry, bool) {
+	if c == nil || ref == "" {
+		return CacheEntry{}, false
+	}
+	entries, err := c.List()
+	if err != nil {
+		return CacheEntry{}, false
+	}
+	for _, entry := range entries {
+		if entry.has(source, ref) {
+			return entry, true
+		}
+	}
+	return CacheEntry{}, false
+}
+
+// Link copies the package with the hash to dst and marks it used.
+func (c *Cache) Link(hash, dst string) error {
+	if c == nil || hash == "" {
+		return errors.New("package is not cached")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, err := c.read(hash)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(dst); err != nil {
+		return errors.Wrapf(err, "failed to remove %s", dst)
+	}
+	if err := copyDir(c.objectPath(hash), dst); err != nil {
+		return errors.Wrapf(err, "failed to copy %s from the cache", hash)
+	}
+	entry.Used = time.Now()
+	return c.write(entry)
+}
+
+// Fetch downloads a package with get into the cache and returns its entry.
+func (c *Cache) Fetch(source, ref string, get func(dst string) error) (CacheEntry, error) {
+	if err := os.MkdirAll(filepath.Join(c.dir, "tmp"), 0755); err != nil {
+		return CacheEntry{}, errors.Wrap(err, "failed to create cache")
+	}
+	tmp, err := os.MkdirTemp(filepath.Join(c.dir, "tmp"), "fetch-")
+	if err != nil {
+		return CacheEntry{}, errors.Wrap(err, "failed to create cache")
+	}
+	defer os.RemoveAll(tmp)
+
+	dst := filepath.Join(tmp, "pkg")
+	if err := get(dst); err != nil {
+		return CacheEntry{}, err
+	}
+	if err := os.RemoveAll(filepath.Join(dst, ".git")); err != nil {
+		return CacheEntry{}, errors.Wrap(err, "failed to remove git metadata")
+	}
+	hash, err := hashDir(dst)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+
+	// Identical content fetched before is stored once
+	if _, err := os.Stat(c.objectPath(hash)); err != nil {
+		if err := os.MkdirAll(filepath.Join(c.dir, "objects"), 0755); err != nil {
+			return CacheEntry{}, errors.Wrap(err, "failed to create cache")
+		}
+		// A concurrent fetch of the same content may have stored it first
+		if err := os.Rename(dst, c.objectPath(hash)); err != nil && !dirExists(c.objectPath(hash)) {
+			return CacheEntry{}, errors.Wrap(err, "failed to store package in the cache")
+		}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	entry, err := c.read(hash)
+	if err != nil {
+		entry = CacheEntry{Hash: hash, Fetched: now}
+	}
+	if !entry.has(source, ref) {
+		entry.Sources = append(entry.Sources, CacheSource{Source: source, Ref: ref})
+	}
+	entry.Used = now
+	return entry, c.write(entry)
+}
+
+// List returns the cached packages, most recently used first.
+func (c *Cache) List() ([]CacheEntry, error) {
+	files, err := filepath.Glob(filepath.Join(c.dir, "objects", "*.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	var entries []CacheEntry
+	for _, file := range files {
+		entry, err := c.read(strings.TrimSuffix(filepath.Base(file), ".yaml"))
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Used.After(entries[j].Used)
+	})
+	return entries, nil
+}
+
+// Prune removes the packages not used since before, and returns them.
+func (c *Cache) Prune(before time.Time) ([]CacheEntry, error) {
+	entries, err := c.List()
+	if err != nil {
+		return nil, err
+	}
+	var pruned []CacheEntry
+	for _, entry := range entries {
+		if !entry.Used.Before(before) {
+			continue
+		}
+		if err := os.RemoveAll(c.objectPath(entry.Hash)); err != nil {
+			return pruned, errors.Wrapf(err, "failed to remove %s", entry.Hash)
+		}
+		if err := os.Remove(c.entryPath(entry.Hash)); err != nil {
+			return pruned, errors.Wrapf(err, "failed to remove %s", entry.Hash)
+		}
+		pruned = append(pruned, entry)
+	}
+	return pruned, nil
+}
+
+// Clear removes every cached package.
+func (c *Cache) Clear() error {
+	return errors.Wrap(os.RemoveAll(c.dir), "failed to clear cache")
+}
+
+func (c *Cache) read(hash string) (CacheEntry, error) {
+	var entry CacheEntry
+	data, err := os.ReadFile(c.entryPath(hash))
+	if err != nil {
+		return entry, errors.Wrapf(err, "failed to read cache entry %s", hash)
+	}
+	if err := yaml.Unmarshal(data, &entry); err != nil {
+		return entry, errors.Wrapf(err, "failed to unmarshal cache entry %s", hash)
+	}
+	return entry, nil
+}
+
+func (c *Cache) write(entry CacheEntry) error {
+	data, err := yaml.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal cache entry")
+	}
+	return errors.Wrap(os.WriteFile(c.entryPath(entry.Hash), data, 0644), "failed to write cache entry")
+}
+
+// objectPath returns the directory of the content, named by the hex digest
+// as colons are not allowed in paths everywhere.
+func (c *Cache) objectPath(hash string) string {
+	return filepath.Join(c.dir, "objects", strings.TrimPrefix(hash, "sha256:"))
+}
+
+func (c *Cache) entryPath(hash string) string {
+	return c.objectPath(hash) + ".yaml"
+}
+
+func dirExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,102 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	get := func(dst string) error {
+		if err := os.MkdirAll(filepath.Join(dst, ".git"), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, "run.yaml"), []byte("actions: {}"), 0644)
+	}
+
+	entry, err := cache.Fetch("github.com/org/pkg", "abc", get)
+	assert.NoError(t, err)
+	assert.NoDirExists(t, filepath.Join(cache.objectPath(entry.Hash), ".git"))
+
+	found, ok := cache.Lookup("github.com/org/pkg", "abc")
+	assert.True(t, ok)
+	assert.Equal(t, entry.Hash, found.Hash)
+	_, ok = cache.Lookup("github.com/org/pkg", "def")
+	assert.False(t, ok)
+
+	dst := filepath.Join(t.TempDir(), "pkg")
+	assert.NoError(t, cache.Link(entry.Hash, dst))
+	assert.FileExists(t, filepath.Join(dst, "run.yaml"))
+	assert.Error(t, cache.Link("sha256:missing", dst))
+
+	pruned, err := cache.Prune(time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Empty(t, pruned)
+	pruned, err = cache.Prune(time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Len(t, pruned, 1)
+	entries, err := cache.List()
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestCache_IdenticalContent(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	get := func(dst string) error {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, "run.yaml"), []byte("actions: {}"), 0644)
+	}
+
+	first, err := cache.Fetch("github.com/org/pkg", "abc", get)
+	assert.NoError(t, err)
+	second, err := cache.Fetch("github.com/fork/pkg", "def", get)
+	assert.NoError(t, err)
+	assert.Equal(t, first.Hash, second.Hash)
+	assert.Equal(t, []CacheSource{
+		{Source: "github.com/org/pkg", Ref: "abc"},
+		{Source: "github.com/fork/pkg", Ref: "def"},
+	}, second.Sources)
+
+	// Fetching a source again does not list it twice
+	again, err := cache.Fetch("github.com/org/pkg", "abc", get)
+	assert.NoError(t, err)
+	assert.Len(t, again.Sources, 2)
+
+	for _, source := range again.Sources {
+		found, ok := cache.Lookup(source.Source, source.Ref)
+		assert.True(t, ok)
+		assert.Equal(t, first.Hash, found.Hash)
+	}
+	entries, err := cache.List()
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestGoGetter_FetchCached(t *testing.T) {
+	cache := NewCache(t.TempDir())
+	entry, err := cache.Fetch("github.com/org/pkg", "abc", func(dst string) error {
+		if err := os.MkdirAll(dst, 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dst, "run.yaml"), []byte("actions:\n  test:\n    cmds: [echo test]\n"), 0644)
+	})
+	assert.NoError(t, err)
+
+	// The locked hash is found in the cache, nothing is downloaded
+	lock, err := ReadLockfile(filepath.Join(t.TempDir(), "run.lock"))
+	assert.NoError(t, err)
+	lock.Set("github.com/org/pkg", LockEntry{Ref: "abc", Hash: entry.Hash})
+	gg := NewGoGetter(false).WithLockfile(lock).WithCache(cache).WithOffline(true)
+	gg.pwd = t.TempDir()
+
+	rf, err := gg.Fetch("github.com/org/pkg")
+	assert.NoError(t, err)
+	assert.Contains(t, rf.Actions, "test")
+	assert.FileExists(t, filepath.Join(gg.path("github.com/org/pkg", ""), "run.yaml"))
+}
@@ -1,10 +1,12 @@
 package loader
 
 import (
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 
 	"github.com/campbel/run/runfile"
 	"github.com/hashicorp/go-getter"
@@ -21,6 +23,25 @@ type GoGetter struct {
 	filepathGlob  func(string) ([]string, error)
 	pwd           string
 	forceDownload bool
+	lock          *Lockfile
+	vendor        bool
+	offline       bool
+	cache         *Cache
+	// dirs holds a mutex per import directory, imports whose constraints
+	// resolve to the same version share the directory
+	dirs sync.Map
+
+	trusted           []PublicKey
+	requireSignatures bool
+}
+
+// MissingImportsError lists the imports that could not be loaded offline.
+type MissingImportsError struct {
+	URIs []string
+}
+
+func (e *MissingImportsError) Error() string {
+	return "imports are not in the cache or vendored, fetch them without --offline:\n  - " + strings.Join(e.URIs, "\n  - ")
 }
 
 func NewGoGetter(forceDownload bool) *GoGetter {
@@ -32,19 +53,95 @@ func NewGoGetter(forceDownload bool) *GoGetter {
 	}
 }
 
+// WithDir sets the directory the imports and vendor directories are in, the
+// directory of the runfile.
+func (g *GoGetter) WithDir(dir string) *GoGetter {
+	g.pwd = dir
+	return g
+}
+
+// WithLockfile pins imports to the lockfile, imports it has no entry for
+// are downloaded and added to it.
+func (g *GoGetter) WithLockfile(lock *Lockfile) *GoGetter {
+	g.lock = lock
+	return g
+}
+
+// WithVendor loads imports from the vendor directory when they are vendored.
+func (g *GoGetter) WithVendor(vendor bool) *GoGetter {
+	g.vendor = vendor
+	return g
+}
+
+// WithOffline loads imports only from the cache and vendor directory, imports
+// that are not there are reported as missing instead of downloaded.
+func (g *GoGetter) WithOffline(offline bool) *GoGetter {
+	g.offline = offline
+	return g
+}
+
+// WithCache shares downloaded packages between projects through the cache.
+func (g *GoGetter) WithCache(cache *Cache) *GoGetter {
+	g.cache = cache
+	return g
+}
+
 func (g *GoGetter) Fetch(src string) (*runfile.Runfile, error) {
-	dst := g.path(src)
-	if _, err := os.Stat(dst); err != nil || g.forceDownload {
-		if err := (&getter.Client{
-			Src:  src,
-			Dst:  g.path(src),
-			Pwd:  g.pwd,
-			Mode: getter.ClientModeAny,
-		}).Get(); err != nil {
+	// Local imports are used in place so edits show up immediately
+	if dir, ok := localPath(src, g.pwd); ok {
+		return g.read(src, dir)
+	}
+
+	entry, locked := g.lock.Get(src)
+	// Versioned imports are stored under the version they resolve to, which
+	// needs the tags of the repository when it is not locked
+	if _, constraint := runfile.SplitVersion(src); constraint != "" && entry.Version == "" {
+		if g.offline {
+			return nil, &MissingImportsError{URIs: []string{src}}
+		}
+		var err error
+		if entry, _, err = g.pin(src, entry); err != nil {
+			return nil, err
+		}
+	}
+
+	dst := g.path(src, entry.Version)
+	defer g.lockDir(dst)()
+	if _, err := os.Stat(g.vendorPath(src, entry.Version)); err == nil && g.vendor {
+		dst = g.vendorPath(src, entry.Version)
+	} else if _, err := os.Stat(dst); err != nil || (!g.offline && (g.forceDownload || (g.lock != nil && !locked))) {
+		if entry, err = g.download(src, dst, entry); err != nil {
 			return nil, err
 		}
 	}
 
+	if g.lock != nil {
+		hash, err := hashDir(dst)
+		if err != nil {
+			return nil, err
+		}
+		if locked && entry.Hash != hash {
+			return nil, errors.Errorf("import %s does not match run.lock, expected %s but found %s", src, entry.Hash, hash)
+		}
+		entry.Hash = hash
+		g.lock.Set(src, entry)
+	}
+
+	if err := g.verify(src, dst); err != nil {
+		return nil, err
+	}
+	return g.read(src, dst)
+}
+
+// lockDir locks the import directory dst until the returned func is called.
+func (g *GoGetter) lockDir(dst string) func() {
+	mu, _ := g.dirs.LoadOrStore(dst, &sync.Mutex{})
+	mu.(*sync.Mutex).Lock()
+	return mu.(*sync.Mutex).Unlock
+}
+
+// read merges the runfiles of the package in dir.
+func (g *GoGetter) read(src, dst string) (*runfile.Runfile, error) {
 	var filepaths []string
 	files, err := g.filepathGlob(filepath.Join(dst, "*.yaml"))
 	if err != nil {
@@ -71,9 +168,116 @@ func (g *GoGetter) Fetch(src string) (*runfile.Runfile, error) {
 			sharedRunfile = runfile.Merge(sharedRunfile, rf)
 		}
 	}
+	if sharedRunfile == nil {
+		return nil, errors.Errorf("no runfile found in import %s", src)
+	}
 	return sharedRunfile.WithDir(dst), nil
 }
 
-func (g *GoGetter) path(imp string) string {
-	return filepath.Join(g.pwd, ".run", "imports", imp)
+// download fetches the import into dst, at the commit it is pinned to, which
+// is returned in the entry.
+func (g *GoGetter) download(src, dst string, entry LockEntry) (LockEntry, error) {
+	// Locked packages are copied from the cache without touching the network
+	if !g.forceDownload && g.cache.Link(entry.Hash, dst) == nil {
+		return entry, nil
+	}
+	if g.offline {
+		return entry, &MissingImportsError{URIs: []string{src}}
+	}
+
+	source, _ := runfile.SplitVersion(src)
+	entry, getSrc, err := g.pin(src, entry)
+	if err != nil {
+		return entry, err
+	}
+
+	get := func(dst string) error {
+		return (&getter.Client{
+			Src:  getSrc,
+			Dst:  dst,
+			Pwd:  g.pwd,
+			Mode: getter.ClientModeAny,
+		}).Get()
+	}
+
+	if g.cache == nil {
+		// Stale files of a previous download would change the hash
+		if err := os.RemoveAll(dst); err != nil {
+			return entry, errors.Wrapf(err, "failed to remove %s", dst)
+		}
+		return entry, get(dst)
+	}
+
+	cached, ok := g.cache.Lookup(source, entry.Ref)
+	if !ok || g.forceDownload {
+		if cached, err = g.cache.Fetch(source, entry.Ref, get); err != nil {
+			return entry, err
+		}
+	}
+	return entry, g.cache.Link(cached.Hash, dst)
+}
+
+// pin resolves a git import to the commit its locked ref, its ref or its
+// highest matching version tag points at, which is returned in the entry along
+// with the source to download.
+func (g *GoGetter) pin(src string, entry LockEntry) (LockEntry, string, error) {
+	source, version := runfile.SplitVersion(src)
+	git, err := parseGitSource(source, g.pwd)
+	if err != nil {
+		return entry, "", err
+	}
+	if git == nil && version != "" {
+		return entry, "", errors.Errorf("import %s has a version but is not a git repository", src)
+	}
+	if git == nil {
+		return entry, source, nil
+	}
+	if git.ref != "" && version != "" {
+		return entry, "", errors.Errorf("import %s has both a ref and a version", src)
+	}
+
+	if entry.Ref == "" {
+		if version != "" {
+			if git.ref, err = git.resolveVersion(version); err != nil {
+				return entry, "", err
+			}
+			entry.Version = git.ref
+		}
+		if entry.Ref, err = git.resolve(); err != nil {
+			return entry, "", err
+		}
+	}
+	return entry, git.withRef(entry.Ref), nil
+}
+
+// localPath returns the directory of an import on this machine.
+func localPath(src, pwd string) (string, bool) {
+	detected, err := getter.Detect(src, pwd, getter.Detectors)
+	if err != nil || !strings.HasPrefix(detected, "file://") {
+		return "", false
+	}
+	u, err := url.Parse(detected)
+	if err != nil {
+		return "", false
+	}
+	return filepath.FromSlash(u.Path), true
+}
+
+// path returns the directory an import is downloaded to, version is the version
+// a versioned import resolved to.
+func (g *GoGetter) path(imp, version string) string {
+	return filepath.Join(g.pwd, ".run", "imports", importDir(imp, version))
+}
+
+func (g *GoGetter) vendorPath(imp, version string) string {
+	return filepath.Join(g.pwd, "run_vendor", importDir(imp, version))
+}
+
+// importDir names the directory of an import after the version it resolved
+// to rather than its constraint, which may hold spaces and operators.
+func importDir(imp, version string) string {
+	if source, constraint := runfile.SplitVersion(imp); constraint != "" {
+		return source + "@" + version
+	}
+	return imp
 }
@@ -13,10 +13,15 @@ import (
 
 func TestGoGetter_Fetch(t *testing.T) {
 	t.Run("fetches runfile", func(t *testing.T) {
+		repo := newGitRepo(t, map[string]string{
+			"simple/run.yaml":                      "actions:\n  test:\n    cmds:\n      - shell: echo \"hello world\"\n",
+			"simple/run_" + runtime.GOOS + ".yaml": "actions:\n  test-os:\n    cmds:\n      - shell: echo \"hello " + runtime.GOOS + "\"\n",
+		})
+		src := "git::file://" + filepath.ToSlash(repo) + "//simple"
 		gg := NewGoGetter(false)
 		gg.pwd = t.TempDir()
 
-		rf, err := gg.Fetch("github.com/campbel/run/loader/testdata/simple")
+		rf, err := gg.Fetch(src)
 		assert.NoError(t, err)
 
 		expected := &runfile.Runfile{
@@ -34,7 +39,7 @@ func TestGoGetter_Fetch(t *testing.T) {
 				},
 			},
 		}
-		expected.WithDir(filepath.Join(gg.pwd, ".run", "imports", "github.com/campbel/run/loader/testdata/simple"))
+		expected.WithDir(gg.path(src, ""))
 		assert.Equal(t, expected, rf)
 	})
 
@@ -74,19 +79,19 @@ actions:
 		gg := NewGoGetter(false)
 		gg.pwd = t.TempDir()
 
-		_, err := gg.Fetch("github.com/campbel/run/does/not/exist")
+		_, err := gg.Fetch("git::file://" + filepath.ToSlash(newGitRepo(t, map[string]string{"README.md": "no runfile"})))
 		assert.Error(t, err)
 	})
 
-	t.Run("returns error when runfile is not found", func(t *testing.T) {
+	t.Run("returns error when glob fails", func(t *testing.T) {
 		gg := NewGoGetter(false)
 		gg.pwd = t.TempDir()
 		gg.filepathGlob = func(string) ([]string, error) {
 			return nil, errors.New("error")
 		}
 
-		_, err := gg.Fetch("github.com/campbel/run/loader/testdata/simple")
-		assert.Error(t, err)
+		_, err := gg.Fetch("git::file://" + filepath.ToSlash(newGitRepo(t, map[string]string{"run.yaml": "actions: {}"})))
+		assert.EqualError(t, err, "error")
 	})
 
 	t.Run("invalid yaml error", func(t *testing.T) {
@@ -122,3 +127,54 @@ actions:
 		assert.Nil(t, rf)
 	})
 }
+
+func TestGoGetter_FetchOffline(t *testing.T) {
+	gg := NewGoGetter(true).WithOffline(true)
+	gg.pwd = t.TempDir()
+
+	cached := map[string]string{
+		"github.com/org/pkg1": "imports:\n  pkg2: github.com/org/pkg2\n  pkg3: github.com/org/pkg3\n",
+		"github.com/org/pkg3": "actions:\n  test:\n    cmds: [echo test]\n",
+	}
+	for uri, content := range cached {
+		assert.NoError(t, os.MkdirAll(gg.path(uri, ""), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(gg.path(uri, ""), "run.yaml"), []byte(content), 0644))
+	}
+
+	rf, err := gg.Fetch("github.com/org/pkg3")
+	assert.NoError(t, err)
+	assert.Contains(t, rf.Actions, "test")
+
+	root := &runfile.Runfile{
+		Imports: map[string]string{
+			"pkg1": "github.com/org/pkg1",
+			"pkg4": "github.com/org/pkg4",
+		},
+	}
+	_, err = NewLoader(root, gg).Load()
+	var missing *MissingImportsError
+	assert.ErrorAs(t, err, &missing)
+	assert.Equal(t, []string{"github.com/org/pkg2", "github.com/org/pkg4"}, missing.URIs)
+}
+
+func TestGoGetter_FetchLocal(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "tools"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "tools", "run.yaml"), []byte("actions:\n  lint:\n    cmds: [echo lint]\n"), 0644))
+
+	lock, err := ReadLockfile(filepath.Join(dir, "run.lock"))
+	assert.NoError(t, err)
+	gg := NewGoGetter(false).WithLockfile(lock)
+	gg.pwd = t.TempDir()
+
+	root := runfile.NewRunfile().WithDir(dir)
+	root.Imports["tools"] = "./tools"
+	l := NewLoader(root, gg)
+	pkg, err := l.Load()
+	assert.NoError(t, err)
+
+	// The import is read in place, not copied or locked
+	assert.Equal(t, filepath.Join(dir, "tools"), pkg.Imports["tools"].Dir)
+	assert.NoDirExists(t, gg.path("", ""))
+	assert.Empty(t, lock.Imports)
+}
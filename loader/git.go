@@ -0,0 +1,131 @@
+package loader
+
+import (
+	"bufio"
+	"bytes"
+	"net/url"
+	"os/exec"
+	"regexp"
+	"strings"
+
+	"github.com/Masterminds/semver"
+	"github.com/hashicorp/go-getter"
+	"github.com/pkg/errors"
+)
+
+var commitPattern = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// gitSource is an import fetched from a git repository.
+type gitSource struct {
+	repo   *url.URL
+	ref    string
+	subdir string
+}
+
+// parseGitSource detects the getter of the import, it returns nil for imports
+// not fetched with git.
+func parseGitSource(src, pwd string) (*gitSource, error) {
+	detected, err := getter.Detect(src, pwd, getter.Detectors)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to detect source of %s", src)
+	}
+	forced, rest, ok := strings.Cut(detected, "::")
+	if !ok || forced != "git" {
+		return nil, nil
+	}
+	source, subdir := getter.SourceDirSubdir(rest)
+	repo, err := url.Parse(source)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse source of %s", src)
+	}
+	query := repo.Query()
+	ref := query.Get("ref")
+	query.Del("ref")
+	repo.RawQuery = query.Encode()
+	return &gitSource{repo: repo, ref: ref, subdir: subdir}, nil
+}
+
+// remote returns the repository url without getter options.
+func (s *gitSource) remote() string {
+	remote := *s.repo
+	remote.RawQuery = ""
+	return remote.String()
+}
+
+// withRef returns a getter source fetching the repository at ref.
+func (s *gitSource) withRef(ref string) string {
+	remote := *s.repo
+	query := remote.Query()
+	query.Set("ref", ref)
+	remote.RawQuery = ""
+	src := "git::" + remote.String()
+	if s.subdir != "" {
+		src += "//" + s.subdir
+	}
+	return src + "?" + query.Encode()
+}
+
+// resolve returns the commit the ref of the source points at.
+func (s *gitSource) resolve() (string, error) {
+	ref := s.ref
+	if commitPattern.MatchString(ref) {
+		return ref, nil
+	}
+	if ref == "" {
+		ref = "HEAD"
+	}
+	out, err := exec.Command("git", "ls-remote", s.remote(), ref, ref+"^{}").Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to resolve %s of %s", ref, s.remote())
+	}
+	// Annotated tags are listed twice, the peeled ^{} entry is the commit
+	var commit string
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		if commit == "" || strings.HasSuffix(fields[1], "^{}") {
+			commit = fields[0]
+		}
+	}
+	if commit == "" {
+		return "", errors.Errorf("no ref %s in %s", ref, s.remote())
+	}
+	return commit, nil
+}
+
+// resolveVersion returns the highest tag of the repository matching the
+// version constraint.
+func (s *gitSource) resolveVersion(version string) (string, error) {
+	constraint, err := semver.NewConstraint(version)
+	if err != nil {
+		return "", errors.Wrapf(err, "invalid version %s", version)
+	}
+	out, err := exec.Command("git", "ls-remote", "--tags", "--refs", s.remote()).Output()
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to list tags of %s", s.remote())
+	}
+	var tag string
+	var highest *semver.Version
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 {
+			continue
+		}
+		name := strings.TrimPrefix(fields[1], "refs/tags/")
+		v, err := semver.NewVersion(name)
+		if err != nil || !constraint.Check(v) {
+			continue
+		}
+		if highest == nil || v.GreaterThan(highest) {
+			tag, highest = name, v
+		}
+	}
+	if tag == "" {
+		return "", errors.Errorf("no tag of %s matches %s", s.remote(), version)
+	}
+	return tag, nil
+}
@@ -0,0 +1,118 @@
+package loader
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGitSource(t *testing.T) {
+	git, err := parseGitSource("github.com/campbel/run//actions/golang?ref=v1.0.0", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://github.com/campbel/run.git", git.remote())
+	assert.Equal(t, "v1.0.0", git.ref)
+	assert.Equal(t, "actions/golang", git.subdir)
+	assert.Equal(t, "git::https://github.com/campbel/run.git//actions/golang?ref=abc", git.withRef("abc"))
+
+	git, err = parseGitSource("https://example.com/run.zip", "")
+	assert.NoError(t, err)
+	assert.Nil(t, git)
+}
+
+// newGitRepo returns a bare git repository with a commit of the files, tagged
+// with each of the tags.
+func newGitRepo(t *testing.T, files map[string]string, tags ...string) string {
+	work, bare := t.TempDir(), t.TempDir()
+	runGit := func(dir string, args ...string) {
+		cmd := exec.Command("git", append([]string{"-c", "user.name=run", "-c", "user.email=run@example.com"}, args...)...)
+		cmd.Dir = dir
+		out, err := cmd.CombinedOutput()
+		assert.NoError(t, err, string(out))
+	}
+	runGit(work, "init", "-q")
+	for name, content := range files {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(work, name)), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(work, name), []byte(content), 0644))
+	}
+	runGit(work, "add", "-A")
+	runGit(work, "commit", "-qm", "init")
+	for _, tag := range tags {
+		runGit(work, "tag", tag)
+	}
+	runGit(bare, "clone", "-q", "--bare", work, ".")
+	return bare
+}
+
+func TestGitSource_resolveVersion(t *testing.T) {
+	repo := newGitRepo(t, map[string]string{"run.yaml": "actions: {}"}, "v1.2.0", "v1.3.1", "v2.0.0", "latest")
+
+	git, err := parseGitSource("git::file://"+filepath.ToSlash(repo), "")
+	assert.NoError(t, err)
+
+	tests := map[string]string{
+		"^1.2":   "v1.3.1",
+		"~1.2":   "v1.2.0",
+		"v2.0.0": "v2.0.0",
+		">=1":    "v2.0.0",
+	}
+	for version, expected := range tests {
+		tag, err := git.resolveVersion(version)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, tag, version)
+	}
+
+	_, err = git.resolveVersion("^3")
+	assert.ErrorContains(t, err, "no tag")
+}
+
+func TestGoGetter_FetchVersion(t *testing.T) {
+	repo := newGitRepo(t, map[string]string{"run.yaml": "actions: {}"}, "v1.2.0", "v1.3.1", "v2.0.0")
+	src := "git::file://" + filepath.ToSlash(repo) + "@>=1.2, <1.4"
+
+	lock, err := ReadLockfile(filepath.Join(t.TempDir(), "run.lock"))
+	assert.NoError(t, err)
+	gg := NewGoGetter(false).WithLockfile(lock)
+	gg.pwd = t.TempDir()
+
+	rf, err := gg.Fetch(src)
+	assert.NoError(t, err)
+
+	// The directory is named after the version, not the constraint
+	dir := filepath.Join(gg.pwd, ".run", "imports", "git::file://"+filepath.ToSlash(repo)+"@v1.3.1")
+	assert.Equal(t, dir, rf.Dir())
+	assert.DirExists(t, dir)
+	assert.Equal(t, "v1.3.1", lock.Imports[src].Version)
+}
+
+func TestGoGetter_FetchSameVersion(t *testing.T) {
+	repo := newGitRepo(t, map[string]string{"run.yaml": "actions: {}", "install.sh": "echo install"}, "v1.2.0", "v1.3.1")
+	srcs := []string{
+		"git::file://" + filepath.ToSlash(repo) + "@^1.2",
+		"git::file://" + filepath.ToSlash(repo) + "@~1.3",
+	}
+
+	lock, err := ReadLockfile(filepath.Join(t.TempDir(), "run.lock"))
+	assert.NoError(t, err)
+	gg := NewGoGetter(false).WithLockfile(lock).WithCache(NewCache(t.TempDir())).WithDir(t.TempDir())
+
+	// Both constraints resolve to v1.3.1 and are fetched into one directory
+	var wg sync.WaitGroup
+	errs := make([]error, len(srcs))
+	for i, src := range srcs {
+		wg.Add(1)
+		go func(i int, src string) {
+			defer wg.Done()
+			_, errs[i] = gg.Fetch(src)
+		}(i, src)
+	}
+	wg.Wait()
+
+	assert.NoError(t, errs[0])
+	assert.NoError(t, errs[1])
+	assert.Equal(t, "v1.3.1", lock.Imports[srcs[0]].Version)
+	assert.Equal(t, lock.Imports[srcs[0]], lock.Imports[srcs[1]])
+}
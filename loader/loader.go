@@ -1,8 +1,14 @@
 package loader
 
 import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
 	"github.com/campbel/run/runfile"
 	"github.com/campbel/run/runner"
+	"github.com/pkg/errors"
 )
 
 type Loader struct {
@@ -12,6 +18,10 @@ type Loader struct {
 	packagesContext map[string]*runner.PackageContext
 
 	fetcher Fetcher
+	workers int
+	global  *runner.GlobalContext
+	lock    *Lockfile
+	policy  *Policy
 }
 
 func NewLoader(root *runfile.Runfile, fetcher Fetcher) *Loader {
@@ -21,30 +31,62 @@ func NewLoader(root *runfile.Runfile, fetcher Fetcher) *Loader {
 		packagesContext: make(map[string]*runner.PackageContext),
 
 		fetcher: fetcher,
+		workers: 8,
+		global:  runner.NewGlobalContext(),
 	}
 }
 
-func (l *Loader) Load() *runner.PackageContext {
-	for _, pkg := range l.main.Imports {
-		l.loadPackage(pkg)
+func (l *Loader) WithGlobalContext(global *runner.GlobalContext) *Loader {
+	l.global = global
+	return l
+}
+
+// WithLockfile saves the lockfile after every load, keeping only the imports
+// in use.
+func (l *Loader) WithLockfile(lock *Lockfile) *Loader {
+	l.lock = lock
+	return l
+}
+
+// WithPolicy checks every import, including transitive ones, against the
+// policy before it is fetched.
+func (l *Loader) WithPolicy(policy *Policy) *Loader {
+	l.policy = policy
+	return l
+}
+
+func (l *Loader) Load() (*runner.PackageContext, error) {
+	if err := l.loadPackages(); err != nil {
+		return nil, err
+	}
+
+	if l.lock != nil {
+		l.lock.Retain(l.declared())
+		if err := l.lock.Save(); err != nil {
+			return nil, err
+		}
 	}
-	return l.loadPackageCtx(runner.NewGlobalContext(), l.main)
+
+	return l.loadPackageCtx(l.global, "", "", l.main), nil
 }
 
-func (l *Loader) loadPackageCtx(global *runner.GlobalContext, rf *runfile.Runfile) *runner.PackageContext {
-	pkg := runner.NewPackageContext(global, rf)
+func (l *Loader) loadPackageCtx(global *runner.GlobalContext, name, uri string, rf *runfile.Runfile) *runner.PackageContext {
+	pkg := runner.NewPackageContext(global, rf).WithName(name).WithURI(uri)
 
 	if rf == nil {
 		return pkg
 	}
 
 	for name, action := range rf.Actions {
-		pkg.Actions[name] = runner.NewActionContext(global, pkg, action)
+		pkg.Actions[name] = runner.NewActionContext(global, pkg, name, action)
 	}
 
-	for name, uri := range rf.Imports {
+	// Imports are visited in order so a package shared by several importers
+	// is always named after the same one
+	for _, name := range runfile.SortedKeys(rf.Imports) {
+		uri := l.resolve(rf.Dir(), rf.Imports[name])
 		if _, ok := l.packagesContext[uri]; !ok {
-			l.packagesContext[uri] = l.loadPackageCtx(global, l.packages[uri])
+			l.packagesContext[uri] = l.loadPackageCtx(global, name, uri, l.packages[uri])
 		}
 		pkg.Imports[name] = l.packagesContext[uri]
 	}
@@ -52,19 +94,178 @@ func (l *Loader) loadPackageCtx(global *runner.GlobalContext, rf *runfile.Runfil
 	return pkg
 }
 
-func (l *Loader) loadPackage(uri string) error {
-	if _, ok := l.packages[uri]; ok {
-		return nil
+// loadPackages fetches the imports of the main runfile and their imports
+// concurrently, each uri once. Every failed import is reported, in order of
+// uri so the error does not depend on which fetch finished first.
+func (l *Loader) loadPackages() error {
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		workers = make(chan struct{}, l.workers)
+		seen    = make(map[string]bool)
+		failed  = make(map[string]error)
+		denied  = make(map[string]error)
+		missing = &MissingImportsError{}
+	)
+
+	var load func(uri string)
+	load = func(uri string) {
+		defer wg.Done()
+
+		if err := l.policy.Check(uri); err != nil {
+			mu.Lock()
+			denied[uri] = err
+			mu.Unlock()
+			return
+		}
+
+		workers <- struct{}{}
+		rf, err := l.fetcher.Fetch(uri)
+		<-workers
+
+		mu.Lock()
+		defer mu.Unlock()
+		var missingErr *MissingImportsError
+		if errors.As(err, &missingErr) {
+			missing.URIs = append(missing.URIs, missingErr.URIs...)
+			return
+		}
+		if err != nil {
+			failed[uri] = err
+			return
+		}
+		l.packages[uri] = rf
+		for _, name := range runfile.SortedKeys(rf.Imports) {
+			l.loadOnce(l.resolve(rf.Dir(), rf.Imports[name]), seen, &wg, load)
+		}
+	}
+
+	mu.Lock()
+	for _, name := range runfile.SortedKeys(l.main.Imports) {
+		l.loadOnce(l.resolve(l.main.Dir(), l.main.Imports[name]), seen, &wg, load)
 	}
-	runfile, err := l.fetcher.Fetch(uri)
-	if err != nil {
-		return err
+	mu.Unlock()
+	wg.Wait()
+
+	if len(denied) > 0 {
+		policyErr := &PolicyError{Policy: l.policy.path}
+		for _, uri := range runfile.SortedKeys(denied) {
+			policyErr.Violations = append(policyErr.Violations, PolicyViolation{
+				URI:    uri,
+				Chain:  l.chain(uri),
+				Reason: denied[uri],
+			})
+		}
+		return policyErr
 	}
-	l.packages[uri] = runfile
-	for _, pkg := range runfile.Imports {
-		if err := l.loadPackage(pkg); err != nil {
-			return err
+	if uris := runfile.SortedKeys(failed); len(uris) == 1 {
+		return errors.Wrapf(failed[uris[0]], "failed to load import %s", uris[0])
+	} else if len(uris) > 1 {
+		messages := make([]string, 0, len(uris))
+		for _, uri := range uris {
+			messages = append(messages, uri+": "+failed[uri].Error())
 		}
+		return errors.Errorf("failed to load imports:\n  - %s", strings.Join(messages, "\n  - "))
+	}
+	if len(missing.URIs) > 0 {
+		sort.Strings(missing.URIs)
+		return missing
 	}
 	return nil
 }
+
+// chain returns the shortest chain of imports from the main runfile to the
+// uri. Imports are visited in order so the chain does not depend on which
+// fetch finished first.
+func (l *Loader) chain(uri string) []string {
+	parents := map[string]string{".": ""}
+	queue := []string{"."}
+	for len(queue) > 0 {
+		from := queue[0]
+		queue = queue[1:]
+		rf := l.main
+		if from != "." {
+			rf = l.packages[from]
+		}
+		if rf == nil {
+			continue
+		}
+		for _, name := range runfile.SortedKeys(rf.Imports) {
+			to := l.resolve(rf.Dir(), rf.Imports[name])
+			if _, ok := parents[to]; ok {
+				continue
+			}
+			parents[to] = from
+			if to == uri {
+				var chain []string
+				for node := to; node != ""; node = parents[node] {
+					chain = append([]string{node}, chain...)
+				}
+				return chain
+			}
+			queue = append(queue, to)
+		}
+	}
+	return []string{".", uri}
+}
+
+// loadOnce starts loading the uri unless it was started before, seen is
+// guarded by the caller.
+func (l *Loader) loadOnce(uri string, seen map[string]bool, wg *sync.WaitGroup, load func(string)) {
+	if seen[uri] {
+		return
+	}
+	seen[uri] = true
+	wg.Add(1)
+	go load(uri)
+}
+
+// declared returns the imports loaded and the imports they replace, so
+// replacing an import for local development leaves the lockfile as it was.
+func (l *Loader) declared() []string {
+	uris := l.Packages()
+	runfiles := []*runfile.Runfile{l.main}
+	for _, uri := range uris {
+		runfiles = append(runfiles, l.packages[uri])
+	}
+	for _, rf := range runfiles {
+		for _, uri := range rf.Imports {
+			uris = append(uris, resolveImport(rf.Dir(), uri))
+		}
+	}
+	return uris
+}
+
+// resolve returns the uri an import declared in dir is loaded from. Replace
+// directives of the main runfile apply to every import, including the imports
+// of imported packages. A directive without a version replaces every version.
+// Relative uris on either side of a directive are relative to the main runfile.
+func (l *Loader) resolve(dir, uri string) string {
+	uri = resolveImport(dir, uri)
+	replace := make(map[string]string, len(l.main.Replace))
+	for key, replacement := range l.main.Replace {
+		replace[resolveImport(l.main.Dir(), key)] = replacement
+	}
+	replacement, ok := replace[uri]
+	if !ok {
+		source, _ := runfile.SplitVersion(uri)
+		if replacement, ok = replace[source]; !ok {
+			return uri
+		}
+	}
+	return resolveImport(l.main.Dir(), replacement)
+}
+
+// resolveImport resolves a relative import against the directory of the
+// runfile that declares it, rather than the directory run was started in.
+func resolveImport(dir, uri string) string {
+	if dir == "" || filepath.IsAbs(uri) {
+		return uri
+	}
+	for _, prefix := range []string{".", ".."} {
+		if uri == prefix || strings.HasPrefix(uri, prefix+"/") || strings.HasPrefix(uri, prefix+string(filepath.Separator)) {
+			return filepath.Join(dir, uri)
+		}
+	}
+	return uri
+}
@@ -2,7 +2,13 @@ package loader
 
 import (
 	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/campbel/run/runfile"
 	"github.com/stretchr/testify/assert"
@@ -100,8 +106,111 @@ func TestLoader_Load(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			assert := assert.New(t)
 			l := NewLoader(root, tt.fetcher)
-			l.Load()
+			_, err := l.Load()
 			assert.Equal(l.packages, tt.expected)
+			if tt.err != nil {
+				assert.ErrorContains(err, tt.err.Error())
+			} else {
+				assert.NoError(err)
+			}
 		})
 	}
 }
+
+func TestLoader_LoadConcurrent(t *testing.T) {
+	// Every package imports a shared package and one of its own
+	root := &runfile.Runfile{Imports: make(map[string]string)}
+	for i := 0; i < 10; i++ {
+		root.Imports[fmt.Sprintf("pkg%d", i)] = fmt.Sprintf("github.com/pkg%d", i)
+	}
+
+	var (
+		mu       sync.Mutex
+		fetched  = make(map[string]int)
+		inFlight atomic.Int32
+		maxSeen  atomic.Int32
+	)
+	fetcher := &mockFetcher{
+		fetch: func(uri string) (*runfile.Runfile, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				seen := maxSeen.Load()
+				if n <= seen || maxSeen.CompareAndSwap(seen, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+
+			mu.Lock()
+			fetched[uri]++
+			mu.Unlock()
+			if uri == "github.com/pkg3" || uri == "github.com/pkg7" {
+				return nil, errors.New("fetch error")
+			}
+			if strings.HasSuffix(uri, "/next") || uri == "github.com/shared" {
+				return &runfile.Runfile{}, nil
+			}
+			return &runfile.Runfile{Imports: map[string]string{
+				"shared": "github.com/shared",
+				"next":   uri + "/next",
+			}}, nil
+		},
+	}
+
+	for i := 0; i < 5; i++ {
+		fetched = make(map[string]int)
+		maxSeen.Store(0)
+
+		l := NewLoader(root, fetcher)
+		l.workers = 4
+		_, err := l.Load()
+
+		assert.EqualError(t, err, "failed to load imports:\n  - github.com/pkg3: fetch error\n  - github.com/pkg7: fetch error")
+		assert.Len(t, l.Packages(), 8+8+1)
+		for uri, count := range fetched {
+			assert.Equal(t, 1, count, uri)
+		}
+		assert.Greater(t, maxSeen.Load(), int32(1))
+		assert.LessOrEqual(t, maxSeen.Load(), int32(4))
+	}
+}
+
+func TestLoader_LoadReplace(t *testing.T) {
+	root := &runfile.Runfile{
+		Imports: map[string]string{
+			"pkg1": "github.com/pkg1@^1.2",
+		},
+		Replace: map[string]string{
+			"github.com/pkg1": "github.com/fork/pkg1",
+			"github.com/pkg2": "../pkg2",
+			"./tools":         "github.com/org/tools",
+		},
+	}
+	root.WithDir("/src/app")
+
+	var fetched []string
+	fetcher := &mockFetcher{
+		fetch: func(uri string) (*runfile.Runfile, error) {
+			fetched = append(fetched, uri)
+			if uri == "github.com/fork/pkg1" {
+				return &runfile.Runfile{Imports: map[string]string{"pkg2": "github.com/pkg2"}}, nil
+			}
+			if uri == "github.com/org/tools" {
+				// Relative imports of the replacement are its own
+				return (&runfile.Runfile{Imports: map[string]string{"lint": "./tools"}}).WithDir("/cache/tools"), nil
+			}
+			return &runfile.Runfile{}, nil
+		},
+	}
+
+	root.Imports["tools"] = "./tools"
+	l := NewLoader(root, fetcher)
+	l.workers = 1
+	pkg, err := l.Load()
+	assert.NoError(t, err)
+	pkg2 := filepath.Join("/src", "pkg2")
+	assert.ElementsMatch(t, []string{"github.com/fork/pkg1", "github.com/org/tools", pkg2, filepath.Join("/cache/tools", "tools")}, fetched)
+	assert.Equal(t, pkg2, pkg.Imports["pkg1"].Imports["pkg2"].URI)
+	assert.Equal(t, "github.com/org/tools", pkg.Imports["tools"].URI)
+}
@@ -0,0 +1,157 @@
+package loader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Lockfile pins every import to the revision and content it was first
+// fetched at, so everyone running the runfile loads the same packages.
+type Lockfile struct {
+	Imports map[string]LockEntry `yaml:"imports"`
+
+	path    string
+	mu      sync.Mutex
+	changed bool
+}
+
+type LockEntry struct {
+	Version string `yaml:"version,omitempty"`
+	Ref     string `yaml:"ref,omitempty"`
+	Hash    string `yaml:"hash"`
+}
+
+// ReadLockfile reads the lockfile at path, a missing file is an empty lockfile.
+func ReadLockfile(path string) (*Lockfile, error) {
+	lock := &Lockfile{
+		Imports: make(map[string]LockEntry),
+		path:    path,
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return lock, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read lockfile")
+	}
+	if err := yaml.Unmarshal(data, lock); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal lockfile %s", path)
+	}
+	if lock.Imports == nil {
+		lock.Imports = make(map[string]LockEntry)
+	}
+	return lock, nil
+}
+
+// Get returns the entry of the import, a nil lockfile has no entries.
+func (l *Lockfile) Get(uri string) (LockEntry, bool) {
+	if l == nil {
+		return LockEntry{}, false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	entry, ok := l.Imports[uri]
+	return entry, ok
+}
+
+func (l *Lockfile) Set(uri string, entry LockEntry) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.Imports[uri] != entry {
+		l.Imports[uri] = entry
+		l.changed = true
+	}
+}
+
+// Retain drops the entries of imports that are no longer used.
+func (l *Lockfile) Retain(uris []string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	used := make(map[string]bool)
+	for _, uri := range uris {
+		used[uri] = true
+	}
+	for uri := range l.Imports {
+		if !used[uri] {
+			delete(l.Imports, uri)
+			l.changed = true
+		}
+	}
+}
+
+// Save writes the lockfile when its entries changed.
+func (l *Lockfile) Save() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.changed {
+		return nil
+	}
+	data, err := yaml.Marshal(l)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal lockfile")
+	}
+	if err := os.WriteFile(l.path, data, 0644); err != nil {
+		return errors.Wrap(err, "failed to write lockfile")
+	}
+	l.changed = false
+	return nil
+}
+
+// hashDir hashes the paths and contents of every file in dir, ignoring git
+// metadata and the excluded files.
+func hashDir(dir string, exclude ...string) (string, error) {
+	// The root may be a symlink, e.g. the temp dir on macOS
+	dir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to hash %s", dir)
+	}
+	hash := sha256.New()
+	err = filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		for _, excluded := range exclude {
+			if filepath.ToSlash(rel) == excluded {
+				return nil
+			}
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", filepath.ToSlash(rel), len(data))
+		hash.Write(data)
+		return nil
+	})
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to hash %s", dir)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
@@ -0,0 +1,69 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLockfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "run.lock")
+
+	lock, err := ReadLockfile(path)
+	assert.NoError(t, err)
+	assert.NoError(t, lock.Save())
+	assert.NoFileExists(t, path)
+
+	lock.Set("github.com/pkg1", LockEntry{Ref: "abc", Hash: "sha256:1"})
+	lock.Set("github.com/pkg2", LockEntry{Hash: "sha256:2"})
+	lock.Retain([]string{"github.com/pkg1"})
+	assert.NoError(t, lock.Save())
+
+	lock, err = ReadLockfile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]LockEntry{
+		"github.com/pkg1": {Ref: "abc", Hash: "sha256:1"},
+	}, lock.Imports)
+}
+
+func TestHashDir(t *testing.T) {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "run.yaml"), []byte("actions: {}"), 0644))
+
+	hash, err := hashDir(dir)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, ".git"), 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".git", "HEAD"), []byte("ref: main"), 0644))
+	unchanged, err := hashDir(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, hash, unchanged)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "run.yaml"), []byte("actions: {x: {}}"), 0644))
+	changed, err := hashDir(dir)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, changed)
+}
+
+func TestGoGetter_FetchLocked(t *testing.T) {
+	lock, err := ReadLockfile(filepath.Join(t.TempDir(), "run.lock"))
+	assert.NoError(t, err)
+	gg := NewGoGetter(false).WithLockfile(lock)
+	gg.pwd = t.TempDir()
+
+	dst := gg.path("github.com/org/pkg", "")
+	assert.NoError(t, os.MkdirAll(dst, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dst, "run.yaml"), []byte("actions:\n  test:\n    cmds: [echo test]\n"), 0644))
+	hash, err := hashDir(dst)
+	assert.NoError(t, err)
+	lock.Set("github.com/org/pkg", LockEntry{Ref: "abc", Hash: hash})
+
+	_, err = gg.Fetch("github.com/org/pkg")
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dst, "run.yaml"), []byte("actions:\n  test:\n    cmds: [echo drifted]\n"), 0644))
+	_, err = gg.Fetch("github.com/org/pkg")
+	assert.ErrorContains(t, err, "does not match run.lock")
+}
@@ -0,0 +1,150 @@
+package loader
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/campbel/run/runfile"
+	"github.com/pkg/errors"
+)
+
+// Edge is an import of the package at From, the main runfile is ".".
+type Edge struct {
+	From string
+	Name string
+	URI  string
+}
+
+// Packages returns the imports loaded, including transitive ones.
+func (l *Loader) Packages() []string {
+	return runfile.SortedKeys(l.packages)
+}
+
+// Graph returns the imports of the main runfile and of every loaded package.
+func (l *Loader) Graph() []Edge {
+	var edges []Edge
+	for _, name := range runfile.SortedKeys(l.main.Imports) {
+		edges = append(edges, Edge{From: ".", Name: name, URI: l.resolve(l.main.Dir(), l.main.Imports[name])})
+	}
+	for _, uri := range l.Packages() {
+		rf := l.packages[uri]
+		for _, name := range runfile.SortedKeys(rf.Imports) {
+			edges = append(edges, Edge{From: uri, Name: name, URI: l.resolve(rf.Dir(), rf.Imports[name])})
+		}
+	}
+	return edges
+}
+
+// Remove drops the entry of the import so it is fetched again.
+func (l *Lockfile) Remove(uri string) {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, ok := l.Imports[uri]; ok {
+		delete(l.Imports, uri)
+		l.changed = true
+	}
+}
+
+// Tidy removes the cached packages that are not in uris and returns their
+// paths.
+func (g *GoGetter) Tidy(uris []string) ([]string, error) {
+	root := g.path("", "")
+	keep := make(map[string]bool)
+	parents := make(map[string]bool)
+	for _, uri := range uris {
+		if _, ok := localPath(uri, g.pwd); ok {
+			continue
+		}
+		entry, _ := g.lock.Get(uri)
+		path := g.path(uri, entry.Version)
+		keep[path] = true
+		for dir := filepath.Dir(path); strings.HasPrefix(dir, root); dir = filepath.Dir(dir) {
+			parents[dir] = true
+		}
+	}
+
+	var removed []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if keep[path] {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if parents[path] || path == root {
+			return nil
+		}
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		removed = append(removed, path)
+		if entry.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	return removed, errors.Wrap(err, "failed to tidy imports")
+}
+
+// Vendor copies the cached packages into the vendor directory, replacing
+// anything vendored before. Packages are loaded from there from then on.
+func (g *GoGetter) Vendor(uris []string) error {
+	if err := os.RemoveAll(g.vendorPath("", "")); err != nil {
+		return errors.Wrap(err, "failed to remove vendored imports")
+	}
+	for _, uri := range uris {
+		// Local imports are used in place, they are part of the project
+		if _, ok := localPath(uri, g.pwd); ok {
+			continue
+		}
+		entry, _ := g.lock.Get(uri)
+		if err := copyDir(g.path(uri, entry.Version), g.vendorPath(uri, entry.Version)); err != nil {
+			return errors.Wrapf(err, "failed to vendor %s", uri)
+		}
+	}
+	return nil
+}
+
+// copyDir copies the files in src to dst, ignoring git metadata.
+func copyDir(src, dst string) error {
+	src, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if entry.IsDir() {
+			if entry.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return os.MkdirAll(target, 0755)
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(target, data, info.Mode().Perm())
+	})
+}
@@ -0,0 +1,55 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoader_Graph(t *testing.T) {
+	root := &runfile.Runfile{
+		Imports: map[string]string{"pkg1": "github.com/pkg1"},
+	}
+	fetcher := &mockFetcher{
+		fetch: func(uri string) (*runfile.Runfile, error) {
+			if uri == "github.com/pkg1" {
+				return &runfile.Runfile{Imports: map[string]string{"pkg2": "github.com/pkg2"}}, nil
+			}
+			return &runfile.Runfile{}, nil
+		},
+	}
+
+	l := NewLoader(root, fetcher)
+	_, err := l.Load()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"github.com/pkg1", "github.com/pkg2"}, l.Packages())
+	assert.Equal(t, []Edge{
+		{From: ".", Name: "pkg1", URI: "github.com/pkg1"},
+		{From: "github.com/pkg1", Name: "pkg2", URI: "github.com/pkg2"},
+	}, l.Graph())
+}
+
+func TestGoGetter_TidyAndVendor(t *testing.T) {
+	dir := t.TempDir()
+	gg := NewGoGetter(false).WithVendor(true).WithDir(dir)
+	for _, uri := range []string{"github.com/org/used", "github.com/org/unused", "example.com/stale"} {
+		assert.NoError(t, os.MkdirAll(gg.path(uri, ""), 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(gg.path(uri, ""), "run.yaml"), []byte("actions:\n  test:\n    cmds: [echo "+uri+"]\n"), 0644))
+	}
+
+	removed, err := gg.Tidy([]string{"github.com/org/used"})
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{gg.path("example.com", ""), gg.path("github.com/org/unused", "")}, removed)
+	assert.DirExists(t, gg.path("github.com/org/used", ""))
+
+	assert.NoError(t, gg.Vendor([]string{"github.com/org/used"}))
+	assert.NoError(t, os.RemoveAll(gg.path("", "")))
+
+	rf, err := gg.Fetch("github.com/org/used")
+	assert.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "run_vendor", "github.com/org/used"), rf.Dir())
+	assert.NoDirExists(t, gg.path("github.com/org/used", ""))
+}
@@ -0,0 +1,181 @@
+package loader
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/hashicorp/go-getter"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// Policy restricts the sources imports are loaded from. Rules are globs over
+// import uris where * also matches /, or a getter scheme such as http::.
+// An import matching a deny rule is never loaded, and when there are allow
+// rules an import must match one of them. Local imports are part of the
+// project and only subject to deny rules.
+type Policy struct {
+	Allow []string `yaml:"allow"`
+	Deny  []string `yaml:"deny"`
+
+	path string
+}
+
+// DefaultPolicy reads the policy at $RUN_POLICY or in the user config
+// directory, e.g. ~/.config/run/policy.yaml. Without one every import is
+// allowed and a nil policy is returned.
+func DefaultPolicy() (*Policy, error) {
+	if path, ok := os.LookupEnv("RUN_POLICY"); ok {
+		return ReadPolicy(path)
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return nil, nil
+	}
+	path := filepath.Join(dir, "run", "policy.yaml")
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil, nil
+	}
+	return ReadPolicy(path)
+}
+
+func ReadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read policy")
+	}
+	policy := &Policy{path: path}
+	if err := yaml.Unmarshal(data, policy); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal policy %s", path)
+	}
+	return policy, nil
+}
+
+func (p *Policy) UnmarshalYAML(node *yaml.Node) error {
+	var raw struct {
+		Allow yaml.Node `yaml:"allow"`
+		Deny  yaml.Node `yaml:"deny"`
+	}
+	if err := node.Decode(&raw); err != nil {
+		return err
+	}
+	var err error
+	if p.Allow, err = decodeRules(&raw.Allow); err != nil {
+		return err
+	}
+	p.Deny, err = decodeRules(&raw.Deny)
+	return err
+}
+
+// decodeRules accepts scheme rules such as http:: unquoted, which YAML reads
+// as a mapping of http: to nothing.
+func decodeRules(node *yaml.Node) ([]string, error) {
+	var rules []string
+	for _, item := range node.Content {
+		if item.Kind == yaml.MappingNode && len(item.Content) == 2 && item.Content[1].Tag == "!!null" {
+			rules = append(rules, item.Content[0].Value+":")
+			continue
+		}
+		var rule string
+		if err := item.Decode(&rule); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
+
+// Check returns why the policy does not allow the import, or nil.
+func (p *Policy) Check(uri string) error {
+	if p == nil {
+		return nil
+	}
+	sources, schemes := importSources(uri)
+	for _, rule := range p.Deny {
+		if matchRule(rule, sources, schemes) {
+			return errors.Errorf("denied by %q", rule)
+		}
+	}
+	if len(p.Allow) == 0 || contains(schemes, "file") {
+		return nil
+	}
+	for _, rule := range p.Allow {
+		if matchRule(rule, sources, schemes) {
+			return nil
+		}
+	}
+	return errors.New("not matched by any allow rule")
+}
+
+// PolicyError lists the imports the policy does not allow, with the chain of
+// imports that introduced each of them.
+type PolicyError struct {
+	Policy     string
+	Violations []PolicyViolation
+}
+
+type PolicyViolation struct {
+	URI    string
+	Chain  []string
+	Reason error
+}
+
+func (e *PolicyError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "imports are not allowed by the policy %s:", e.Policy)
+	for _, v := range e.Violations {
+		fmt.Fprintf(&b, "\n  - %s: %s\n    import chain: %s", v.URI, v.Reason, strings.Join(v.Chain, " -> "))
+	}
+	return b.String()
+}
+
+// importSources returns the forms of an import rules are matched against, as
+// written, as detected by go-getter and as host and path, and its schemes.
+func importSources(uri string) ([]string, []string) {
+	sources := []string{uri}
+	var schemes []string
+	add := func(src string) {
+		forced, rest, ok := strings.Cut(src, "::")
+		if ok && !strings.Contains(forced, "/") {
+			schemes = append(schemes, forced)
+		} else {
+			rest = src
+		}
+		if u, err := url.Parse(rest); err == nil && u.Scheme != "" {
+			schemes = append(schemes, u.Scheme)
+			sources = append(sources, strings.TrimPrefix(u.Host+u.Path, "/"))
+		}
+	}
+	add(uri)
+	if detected, err := getter.Detect(uri, "", getter.Detectors); err == nil && detected != uri {
+		sources = append(sources, detected)
+		add(detected)
+	}
+	return sources, schemes
+}
+
+func matchRule(rule string, sources, schemes []string) bool {
+	if scheme, ok := strings.CutSuffix(rule, "::"); ok {
+		return contains(schemes, scheme)
+	}
+	pattern := regexp.MustCompile("^" + strings.ReplaceAll(regexp.QuoteMeta(rule), `\*`, ".*") + "$")
+	for _, source := range sources {
+		if pattern.MatchString(source) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
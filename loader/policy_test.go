@@ -0,0 +1,79 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy_Check(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policy.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("allow:\n- github.com/our-org/*\ndeny:\n- http::\n- github.com/our-org/legacy*\n"), 0644))
+	policy, err := ReadPolicy(path)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http::"}, policy.Deny[:1])
+
+	tests := map[string]bool{
+		"github.com/our-org/actions/golang":                  true,
+		"github.com/our-org/actions@^1.2":                    true,
+		"git::https://github.com/our-org/actions.git//go":    true,
+		"github.com/other-org/actions":                       false,
+		"github.com/our-org/legacy-actions":                  false,
+		"git::https://github.com/our-org/legacy-actions.git": false,
+		"http::github.com/our-org/actions.zip":               false,
+		"http://github.com/our-org/actions.zip":              false,
+		"/src/app/tools":                                     true,
+	}
+	for uri, allowed := range tests {
+		err := policy.Check(uri)
+		if allowed {
+			assert.NoError(t, err, uri)
+		} else {
+			assert.Error(t, err, uri)
+		}
+	}
+
+	var nilPolicy *Policy
+	assert.NoError(t, nilPolicy.Check("http://example.com"))
+}
+
+func TestLoader_LoadPolicy(t *testing.T) {
+	root := &runfile.Runfile{
+		Imports: map[string]string{
+			"a": "github.com/our-org/a",
+			"b": "github.com/our-org/b",
+		},
+	}
+	var mu sync.Mutex
+	var fetched []string
+	fetcher := &mockFetcher{
+		fetch: func(uri string) (*runfile.Runfile, error) {
+			mu.Lock()
+			fetched = append(fetched, uri)
+			mu.Unlock()
+			switch uri {
+			case "github.com/our-org/a":
+				return &runfile.Runfile{Imports: map[string]string{"b": "github.com/our-org/b"}}, nil
+			case "github.com/our-org/b":
+				return &runfile.Runfile{Imports: map[string]string{"evil": "github.com/evil/pkg"}}, nil
+			}
+			return &runfile.Runfile{}, nil
+		},
+	}
+
+	l := NewLoader(root, fetcher).WithPolicy(&Policy{Allow: []string{"github.com/our-org/*"}, path: "policy.yaml"})
+	_, err := l.Load()
+
+	var policyErr *PolicyError
+	assert.ErrorAs(t, err, &policyErr)
+	assert.Equal(t, []PolicyViolation{{
+		URI:    "github.com/evil/pkg",
+		Chain:  []string{".", "github.com/our-org/b", "github.com/evil/pkg"},
+		Reason: policyErr.Violations[0].Reason,
+	}}, policyErr.Violations)
+	assert.NotContains(t, fetched, "github.com/evil/pkg")
+}
@@ -0,0 +1,251 @@
+package loader
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	signatureFile    = "run.sig"
+	minisignFile     = "run.minisig"
+	publicKeyPrefix  = "ed25519:"
+	privateKeyHeader = "run-signing-key:v1\n"
+	signedHeader     = "run-signature:v1\n"
+)
+
+// Signature is the content of run.sig, an ed25519 signature of the message
+// SignedMessage returns.
+type Signature struct {
+	Key       string `yaml:"key"`
+	Signature string `yaml:"signature"`
+}
+
+// PublicKey is a key trusted to sign packages, a run key or a minisign key.
+type PublicKey struct {
+	key ed25519.PublicKey
+	// id is the minisign key id, signatures name the key they are made with
+	id []byte
+}
+
+// minisignature is the content of run.minisig, a minisign signature of the
+// message SignedMessage returns.
+type minisignature struct {
+	prehashed      bool
+	id             []byte
+	signature      []byte
+	trustedComment string
+	globalSig      []byte
+}
+
+// GenerateKey returns a public key, in the form trusted_keys takes, and the
+// private key file content to sign packages with.
+func GenerateKey() (string, []byte, error) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return "", nil, errors.Wrap(err, "failed to generate key")
+	}
+	encoded := privateKeyHeader + base64.StdEncoding.EncodeToString(private.Seed()) + "\n"
+	return encodePublicKey(public), []byte(encoded), nil
+}
+
+// ParsePublicKey parses a key of trusted_keys, either ed25519: followed by a
+// base64 key or the base64 line of a minisign public key.
+func ParsePublicKey(key string) (PublicKey, error) {
+	if strings.HasPrefix(key, publicKeyPrefix) {
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(key, publicKeyPrefix))
+		if err == nil && len(data) == ed25519.PublicKeySize {
+			return PublicKey{key: ed25519.PublicKey(data)}, nil
+		}
+	} else if data, err := base64.StdEncoding.DecodeString(key); err == nil && len(data) == 10+ed25519.PublicKeySize && string(data[:2]) == "Ed" {
+		return PublicKey{key: ed25519.PublicKey(data[10:]), id: data[2:10]}, nil
+	}
+	return PublicKey{}, errors.Errorf("invalid public key '%s', expected %s followed by a base64 key or a minisign public key", key, publicKeyPrefix)
+}
+
+func ParsePrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	if !strings.HasPrefix(string(data), privateKeyHeader) {
+		return nil, errors.New("not a run signing key")
+	}
+	seed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(string(data), privateKeyHeader)))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		return nil, errors.New("invalid run signing key")
+	}
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// SignPackage writes the run.sig of the package in dir.
+func SignPackage(dir string, key ed25519.PrivateKey) error {
+	message, err := SignedMessage(dir)
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(Signature{
+		Key:       encodePublicKey(key.Public().(ed25519.PublicKey)),
+		Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(key, message)),
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal signature")
+	}
+	return errors.Wrap(os.WriteFile(filepath.Join(dir, signatureFile), data, 0644), "failed to write signature")
+}
+
+// WithSignatures checks the signatures of imports against the trusted keys.
+// Badly signed imports always fail to load, unsigned imports only when
+// signatures are required. Local imports are not checked.
+func (g *GoGetter) WithSignatures(trusted []PublicKey, require bool) *GoGetter {
+	g.trusted = trusted
+	g.requireSignatures = require
+	return g
+}
+
+func (g *GoGetter) verify(src, dir string) error {
+	if len(g.trusted) == 0 && !g.requireSignatures {
+		return nil
+	}
+
+	if data, err := os.ReadFile(filepath.Join(dir, minisignFile)); err == nil {
+		return g.verifyMinisign(src, dir, data)
+	} else if !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to read signature of %s", src)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, signatureFile))
+	if os.IsNotExist(err) {
+		if g.requireSignatures {
+			return errors.Errorf("import %s is not signed", src)
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrapf(err, "failed to read signature of %s", src)
+	}
+	var signature Signature
+	if err := yaml.Unmarshal(data, &signature); err != nil {
+		return errors.Wrapf(err, "failed to unmarshal signature of %s", src)
+	}
+
+	// A signature by a key that is not trusted is as good as none
+	key, err := ParsePublicKey(signature.Key)
+	if err != nil {
+		return errors.Wrapf(err, "invalid signature of %s", src)
+	}
+	if !trusts(g.trusted, key.key) {
+		if g.requireSignatures {
+			return errors.Errorf("import %s is signed by %s, which is not a trusted key", src, signature.Key)
+		}
+		return nil
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature.Signature)
+	if err != nil {
+		return errors.Wrapf(err, "invalid signature of %s", src)
+	}
+	message, err := SignedMessage(dir)
+	if err != nil {
+		return err
+	}
+	if !ed25519.Verify(key.key, message, sig) {
+		return errors.Errorf("import %s does not match its signature, it was changed after it was signed", src)
+	}
+	return nil
+}
+
+func (g *GoGetter) verifyMinisign(src, dir string, data []byte) error {
+	signature, err := parseMinisignature(data)
+	if err != nil {
+		return errors.Wrapf(err, "invalid signature of %s", src)
+	}
+
+	key, ok := trustedID(g.trusted, signature.id)
+	if !ok {
+		if g.requireSignatures {
+			return errors.Errorf("import %s is signed by minisign key %016X, which is not a trusted key", src, binary.LittleEndian.Uint64(signature.id))
+		}
+		return nil
+	}
+
+	message, err := SignedMessage(dir)
+	if err != nil {
+		return err
+	}
+	if signature.prehashed {
+		hash := blake2b.Sum512(message)
+		message = hash[:]
+	}
+	if !ed25519.Verify(key.key, message, signature.signature) {
+		return errors.Errorf("import %s does not match its signature, it was changed after it was signed", src)
+	}
+	global := append(append([]byte{}, signature.signature...), signature.trustedComment...)
+	if !ed25519.Verify(key.key, global, signature.globalSig) {
+		return errors.Errorf("the trusted comment of the signature of %s was changed after it was signed", src)
+	}
+	return nil
+}
+
+// parseMinisignature parses the four lines of a minisign signature file.
+func parseMinisignature(data []byte) (minisignature, error) {
+	lines := strings.Split(strings.TrimRight(string(data), "\r\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "untrusted comment: ") || !strings.HasPrefix(lines[2], "trusted comment: ") {
+		return minisignature{}, errors.New("not a minisign signature")
+	}
+	sig, err := base64.StdEncoding.DecodeString(lines[1])
+	if err != nil || len(sig) != 10+ed25519.SignatureSize || (string(sig[:2]) != "Ed" && string(sig[:2]) != "ED") {
+		return minisignature{}, errors.New("invalid minisign signature")
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(lines[3])
+	if err != nil || len(globalSig) != ed25519.SignatureSize {
+		return minisignature{}, errors.New("invalid minisign global signature")
+	}
+	return minisignature{
+		prehashed:      string(sig[:2]) == "ED",
+		id:             sig[2:10],
+		signature:      sig[10:],
+		trustedComment: strings.TrimPrefix(lines[2], "trusted comment: "),
+		globalSig:      globalSig,
+	}, nil
+}
+
+// SignedMessage is what the signature of the package in dir signs, the hash
+// of every file but the signatures.
+func SignedMessage(dir string) ([]byte, error) {
+	hash, err := hashDir(dir, signatureFile, minisignFile)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(signedHeader + hash), nil
+}
+
+func trusts(trusted []PublicKey, key ed25519.PublicKey) bool {
+	for _, t := range trusted {
+		if t.key.Equal(key) {
+			return true
+		}
+	}
+	return false
+}
+
+func trustedID(trusted []PublicKey, id []byte) (PublicKey, bool) {
+	for _, t := range trusted {
+		if t.id != nil && bytes.Equal(t.id, id) {
+			return t, true
+		}
+	}
+	return PublicKey{}, false
+}
+
+func encodePublicKey(key ed25519.PublicKey) string {
+	return publicKeyPrefix + base64.StdEncoding.EncodeToString(key)
+}
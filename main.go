@@ -2,56 +2,183 @@ package main
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
 	"text/tabwriter"
 
 	"github.com/campbel/run/loader"
 	"github.com/campbel/run/runfile"
+	"github.com/campbel/run/runner"
 	"github.com/campbel/yoshi"
 	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
 )
 
 type Options struct {
-	Action   string            `yoshi:"ACTION;The action to run;default"`
-	Vars     map[string]string `yoshi:"--vars,-v;The vars file to use"`
-	Runfile  string            `yoshi:"--runfile,-f;The runfile to use;run.yaml"`
-	List     bool              `yoshi:"--list,-l;List actions"`
-	Download bool              `yoshi:"--download,-d;Force download dependencies"`
+	Action    string            `yoshi:"ACTION;The action to run;default"`
+	Vars      map[string]string `yoshi:"--vars,-v;The vars file to use"`
+	VarsFiles []string          `yoshi:"--vars-file;YAML or JSON files of values, merged in order"`
+	Runfile   string            `yoshi:"--runfile,-f;The runfile to use;run.yaml"`
+	List      bool              `yoshi:"--list,-l;List actions"`
+	Download  bool              `yoshi:"--download,-d;Force download dependencies"`
+	Offline   bool              `yoshi:"--offline;Only load imports from the cache or vendor directory, also set by RUN_OFFLINE=1"`
+	Yes       bool              `yoshi:"--yes,-y;Confirm every action and never prompt for input"`
+	DebugSkip bool              `yoshi:"--debug-skip;Show the output of skip checks"`
+	Summary   bool              `yoshi:"--summary,-s;Print a summary of every action run"`
+	Events    string            `yoshi:"--events;Stream run events, as json to stderr or json=FILE"`
+	Report    string            `yoshi:"--report;Write a report of the run, as junit=FILE"`
+	Trace     string            `yoshi:"--trace;Write a Chrome trace of the run to the file"`
+	Output    string            `yoshi:"--output,-o;How command output is written: raw, interleaved or grouped;raw"`
+	Color     bool              `yoshi:"--color;Color the action prefix of interleaved output"`
+	CI        string            `yoshi:"--ci;Group output for a CI platform: github, gitlab or none, detected when unset"`
+	Verbose   bool              `yoshi:"--verbose,-x;Echo rendered commands, resolved vars and skip checks"`
+	Quiet     bool              `yoshi:"--quiet,-q;Hide the stdout of commands unless they fail"`
+	Profile   string            `yoshi:"--profile,-p;The profile of the runfile to run with"`
 }
 
 func main() {
+	if len(os.Args) > 1 && !hasAction(os.Args[1]) {
+		switch os.Args[1] {
+		case "secrets":
+			yoshi.New("run secrets").RunWithArgs(secretsCommands, os.Args[2:]...)
+			return
+		case "mod":
+			yoshi.New("run mod").RunWithArgs(modCommands, os.Args[2:]...)
+			return
+		case "cache":
+			yoshi.New("run cache").RunWithArgs(cacheCommands, os.Args[2:]...)
+			return
+		case "sign":
+			yoshi.New("run sign").RunWithArgs(signCommands, os.Args[2:]...)
+			return
+		}
+	}
+
 	yoshi.New("run").Run(func(options Options) error {
 
 		runfilePath := filepath.Join(pwd, options.Runfile)
-		if _, err := os.Stat(runfilePath); err != nil {
-			return errors.Wrap(err, "failed to find runfile")
+		runfile, err := readRunfile(runfilePath)
+		if err != nil {
+			return err
 		}
 
-		data, err := os.ReadFile(runfilePath)
+		if options.List {
+			listActions(runfile.Actions)
+			return nil
+		}
+
+		outputMode, err := runner.ParseOutputMode(options.Output)
 		if err != nil {
-			return errors.Wrap(err, "failed to read runfile")
+			return err
+		}
+
+		ci := runner.DetectCI()
+		if options.CI != "" {
+			if ci, err = runner.ParseCI(options.CI); err != nil {
+				return err
+			}
 		}
 
-		runfile, err := runfile.Unmarshal(data)
+		global := runner.NewGlobalContext().
+			WithAssumeYes(options.Yes).
+			WithSkipOutput(options.DebugSkip).
+			WithOutputMode(outputMode).
+			WithQuiet(options.Quiet).
+			WithColor(options.Color).
+			WithCI(ci).
+			WithVerbose(options.Verbose).
+			WithSecrets(runfile.Secrets)
+
+		// Values files provide args, which the vars flag overrides
+		values, err := runner.ReadValues(options.VarsFiles...)
 		if err != nil {
-			return errors.Wrap(err, "failed to unmarshal runfile")
+			return err
+		}
+		global.WithValues(values)
+		args := runner.ValuesArgs(values)
+		for name, value := range options.Vars {
+			args[name] = value
 		}
 
-		if options.List {
-			listActions(runfile.Actions)
-			return nil
+		if options.Profile != "" {
+			profile, err := runfile.Profile(options.Profile)
+			if err != nil {
+				return err
+			}
+			global.WithProfile(options.Profile, profile)
+		}
+
+		if options.Summary {
+			summary := runner.NewSummary()
+			global.WithObserver(summary)
+			defer summary.Print(os.Stdout)
+		}
+
+		var events *runner.JSONEvents
+		if options.Events != "" {
+			format, path := splitFormat(options.Events)
+			if format != "json" {
+				return fmt.Errorf("unsupported events format '%s'", format)
+			}
+			out, err := createOutput(path)
+			if err != nil {
+				return errors.Wrap(err, "failed to create events output")
+			}
+			defer out.Close()
+			events = runner.NewJSONEvents(out)
+			global.WithObserver(events)
+		}
+
+		if options.Report != "" {
+			format, path := splitFormat(options.Report)
+			if format != "junit" || path == "" {
+				return fmt.Errorf("unsupported report '%s', expected junit=FILE", options.Report)
+			}
+			report := runner.NewJUnitReport()
+			global.WithObserver(report).WithCapturedStderr(true)
+			defer writeReport("report", path, report)
 		}
 
-		mainPkg := loader.NewLoader(runfile, loader.NewGoGetter(options.Download)).Load()
+		if options.Trace != "" {
+			trace := runner.NewTrace()
+			global.WithObserver(trace)
+			defer writeReport("trace", options.Trace, trace)
+		}
+
+		lock, err := loader.ReadLockfile(lockfilePath(runfilePath))
+		if err != nil {
+			return err
+		}
+		fetcher, err := newGetter(runfile, lock, options.Download, offline(options.Offline))
+		if err != nil {
+			return err
+		}
+
+		l, err := newLoader(runfile, fetcher, lock)
+		if err != nil {
+			return err
+		}
+		mainPkg, err := l.WithGlobalContext(global).Load()
+		if err != nil {
+			return err
+		}
 
 		action, ok := mainPkg.Actions[options.Action]
 		if !ok {
 			return fmt.Errorf("no action with the name '%s'", options.Action)
 		}
 
-		return action.Run(options.Vars)
+		if err := action.Run(args); err != nil {
+			return err
+		}
+		if events != nil {
+			return events.Err()
+		}
+		return nil
 	})
 }
 
@@ -63,6 +190,108 @@ var pwd = (func() string {
 	return wd
 })()
 
+func readRunfile(path string) (*runfile.Runfile, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, errors.Wrap(err, "failed to find runfile")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read runfile")
+	}
+
+	rf, err := runfile.Unmarshal(data)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal runfile")
+	}
+
+	// Replace directives for local development are kept out of the runfile in
+	// an uncommitted run.local.yaml
+	localPath := filepath.Join(filepath.Dir(path), "run.local.yaml")
+	if data, err := os.ReadFile(localPath); err == nil {
+		var fields map[string]any
+		if err := yaml.Unmarshal(data, &fields); err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal run.local.yaml")
+		}
+		for _, field := range runfile.SortedKeys(fields) {
+			if field != "replace" {
+				return nil, errors.Errorf("run.local.yaml only supports replace, found '%s'", field)
+			}
+		}
+		local, err := runfile.Unmarshal(data)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to unmarshal run.local.yaml")
+		}
+		if rf.Replace == nil {
+			rf.Replace = make(map[string]string)
+		}
+		for uri, replacement := range local.Replace {
+			rf.Replace[uri] = replacement
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, errors.Wrap(err, "failed to read run.local.yaml")
+	}
+
+	return rf.WithDir(filepath.Dir(path)), nil
+}
+
+// hasAction reports whether the runfile in the working directory has the
+// action, which takes precedence over a subcommand of the same name.
+func hasAction(name string) bool {
+	rf, err := readRunfile(filepath.Join(pwd, "run.yaml"))
+	if err != nil {
+		return false
+	}
+	_, ok := rf.Actions[name]
+	return ok
+}
+
+// offline reports whether imports must not be downloaded, by flag or by the
+// RUN_OFFLINE env var.
+func offline(flag bool) bool {
+	env, _ := strconv.ParseBool(os.Getenv("RUN_OFFLINE"))
+	return flag || env
+}
+
+// newGetter returns the fetcher of the imports of the runfile, checking them
+// against the keys it trusts. Imports are kept next to the runfile.
+func newGetter(rf *runfile.Runfile, lock *loader.Lockfile, download, offline bool) (*loader.GoGetter, error) {
+	cache, err := loader.DefaultCache()
+	if err != nil {
+		return nil, err
+	}
+	trusted := make([]loader.PublicKey, 0, len(rf.TrustedKeys))
+	for _, key := range rf.TrustedKeys {
+		publicKey, err := loader.ParsePublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		trusted = append(trusted, publicKey)
+	}
+	return loader.NewGoGetter(download).
+		WithDir(rf.Dir()).
+		WithLockfile(lock).
+		WithCache(cache).
+		WithVendor(true).
+		WithOffline(offline).
+		WithSignatures(trusted, rf.RequireSignatures), nil
+}
+
+// newLoader returns the loader of the imports of the runfile, enforcing the
+// import policy of the user.
+func newLoader(rf *runfile.Runfile, fetcher loader.Fetcher, lock *loader.Lockfile) (*loader.Loader, error) {
+	policy, err := loader.DefaultPolicy()
+	if err != nil {
+		return nil, err
+	}
+	return loader.NewLoader(rf, fetcher).WithLockfile(lock).WithPolicy(policy), nil
+}
+
+// lockfilePath returns the path of the lockfile next to the runfile.
+func lockfilePath(runfilePath string) string {
+	return filepath.Join(filepath.Dir(runfilePath), "run.lock")
+}
+
 func listActions(actions map[string]runfile.Action) {
 	var actionNames []string
 	for name := range actions {
@@ -80,3 +309,44 @@ func listActions(actions map[string]runfile.Action) {
 	}
 	tabwriter.Flush()
 }
+
+// splitFormat splits an output flag of the form FORMAT or FORMAT=FILE.
+func splitFormat(value string) (string, string) {
+	format, path, _ := strings.Cut(value, "=")
+	return format, path
+}
+
+// createOutput creates the file at path, or falls back to stderr when no path
+// is given.
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "" {
+		return nopCloser{os.Stderr}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+type reportWriter interface {
+	Write(io.Writer) error
+}
+
+// writeReport writes the report to path, failures are printed with the label
+// of the report.
+func writeReport(label, path string, report reportWriter) {
+	file, err := os.Create(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create %s: %s\n", label, err)
+		return
+	}
+	defer file.Close()
+	if err := report.Write(file); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write %s: %s\n", label, err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadRunfile_Local(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "run.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+imports:
+  go: github.com/campbel/run/actions/golang
+replace:
+  github.com/org/tools: ../tools
+  github.com/org/lint: ../lint
+`), 0644))
+
+	rf, err := readRunfile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, dir, rf.Dir())
+
+	local := filepath.Join(dir, "run.local.yaml")
+	assert.NoError(t, os.WriteFile(local, []byte(`
+replace:
+  github.com/campbel/run/actions/golang: ../run-actions/golang
+  github.com/org/tools: ../my-tools
+`), 0644))
+
+	rf, err = readRunfile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"github.com/campbel/run/actions/golang": "../run-actions/golang",
+		"github.com/org/tools":                  "../my-tools",
+		"github.com/org/lint":                   "../lint",
+	}, rf.Replace)
+	assert.Equal(t, map[string]string{"go": "github.com/campbel/run/actions/golang"}, rf.Imports)
+
+	assert.NoError(t, os.WriteFile(local, []byte(`
+replace:
+  github.com/org/tools: ../my-tools
+actions:
+  local:
+    cmds: [echo local]
+`), 0644))
+	_, err = readRunfile(path)
+	assert.EqualError(t, err, "run.local.yaml only supports replace, found 'actions'")
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/campbel/run/loader"
+	"github.com/campbel/run/runfile"
+)
+
+type ModCommands struct {
+	Update func(ModUpdateOptions) error
+	Tidy   func(ModOptions) error
+	Vendor func(ModOptions) error
+	Graph  func(ModOptions) error
+}
+
+type ModOptions struct {
+	Runfile string `yoshi:"--runfile,-f;The runfile to use;run.yaml"`
+	Offline bool   `yoshi:"--offline;Only load imports from the cache or vendor directory, also set by RUN_OFFLINE=1"`
+}
+
+type ModUpdateOptions struct {
+	Import string `yoshi:"IMPORT;The name or uri of the import to update, all imports when unset"`
+	ModOptions
+}
+
+var modCommands = ModCommands{
+	Update: func(options ModUpdateOptions) error {
+		if offline(options.Offline) {
+			return fmt.Errorf("imports cannot be updated offline")
+		}
+		rf, lock, fetcher, err := readModRunfile(options.ModOptions)
+		if err != nil {
+			return err
+		}
+		previous := make(map[string]loader.LockEntry)
+		for uri, entry := range lock.Imports {
+			previous[uri] = entry
+		}
+
+		// Imports without a lock entry are fetched and pinned again
+		switch uri, ok := rf.Imports[options.Import]; {
+		case options.Import == "":
+			for uri := range previous {
+				lock.Remove(uri)
+			}
+		case ok:
+			lock.Remove(uri)
+		default:
+			if _, ok := previous[options.Import]; !ok {
+				return fmt.Errorf("no import with the name or uri '%s'", options.Import)
+			}
+			lock.Remove(options.Import)
+		}
+
+		l, err := newLoader(rf, fetcher.WithVendor(false), lock)
+		if err != nil {
+			return err
+		}
+		if _, err := l.Load(); err != nil {
+			return err
+		}
+		for _, uri := range l.Packages() {
+			entry, _ := lock.Get(uri)
+			if old, ok := previous[uri]; ok && old != entry {
+				fmt.Printf("%s %s -> %s\n", uri, describeEntry(old), describeEntry(entry))
+			}
+		}
+		return nil
+	},
+	Tidy: func(options ModOptions) error {
+		rf, lock, fetcher, err := readModRunfile(options)
+		if err != nil {
+			return err
+		}
+		l, err := newLoader(rf, fetcher, lock)
+		if err != nil {
+			return err
+		}
+		if _, err := l.Load(); err != nil {
+			return err
+		}
+		removed, err := fetcher.Tidy(l.Packages())
+		for _, path := range removed {
+			fmt.Println("removed", path)
+		}
+		return err
+	},
+	Vendor: func(options ModOptions) error {
+		rf, lock, fetcher, err := readModRunfile(options)
+		if err != nil {
+			return err
+		}
+		l, err := newLoader(rf, fetcher.WithVendor(false), lock)
+		if err != nil {
+			return err
+		}
+		if _, err := l.Load(); err != nil {
+			return err
+		}
+		return fetcher.Vendor(l.Packages())
+	},
+	Graph: func(options ModOptions) error {
+		rf, lock, fetcher, err := readModRunfile(options)
+		if err != nil {
+			return err
+		}
+		l, err := newLoader(rf, fetcher, lock)
+		if err != nil {
+			return err
+		}
+		if _, err := l.Load(); err != nil {
+			return err
+		}
+		for _, edge := range l.Graph() {
+			fmt.Println(edge.From, edge.URI)
+		}
+		return nil
+	},
+}
+
+func readModRunfile(options ModOptions) (*runfile.Runfile, *loader.Lockfile, *loader.GoGetter, error) {
+	runfilePath := filepath.Join(pwd, options.Runfile)
+	rf, err := readRunfile(runfilePath)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	lock, err := loader.ReadLockfile(lockfilePath(runfilePath))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	fetcher, err := newGetter(rf, lock, false, offline(options.Offline))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return rf, lock, fetcher, nil
+}
+
+// describeEntry describes the revision a lock entry pins, its version when it
+// has one.
+func describeEntry(entry loader.LockEntry) string {
+	if entry.Version != "" {
+		return entry.Version
+	}
+	if entry.Ref != "" {
+		return entry.Ref
+	}
+	return entry.Hash
+}
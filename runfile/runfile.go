@@ -1,10 +1,22 @@
 package runfile
 
+import (
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
 type Runfile struct {
-	dir     string
-	env     map[string]string `yaml:"env" mapstructure:"env"`
-	Imports map[string]string `yaml:"imports" mapstructure:"imports"`
-	Actions map[string]Action `yaml:"actions" mapstructure:"actions"`
+	dir               string
+	env               map[string]string  `yaml:"env" mapstructure:"env"`
+	Imports           map[string]string  `yaml:"imports" mapstructure:"imports"`
+	Replace           map[string]string  `yaml:"replace" mapstructure:"replace"`
+	TrustedKeys       []string           `yaml:"trusted_keys" mapstructure:"trusted_keys"`
+	RequireSignatures bool               `yaml:"require_signatures" mapstructure:"require_signatures"`
+	Secrets           map[string]string  `yaml:"secrets" mapstructure:"secrets"`
+	Profiles          map[string]Profile `yaml:"profiles" mapstructure:"profiles"`
+	Actions           map[string]Action  `yaml:"actions" mapstructure:"actions"`
 }
 
 func NewRunfile() *Runfile {
@@ -33,6 +45,15 @@ func (r *Runfile) Env() map[string]string {
 	return r.env
 }
 
+// Profile returns the profile with the name.
+func (r *Runfile) Profile(name string) (Profile, error) {
+	profile, ok := r.Profiles[name]
+	if !ok {
+		return Profile{}, errors.Errorf("no profile with the name '%s'", name)
+	}
+	return profile, nil
+}
+
 func Merge(rfs ...*Runfile) *Runfile {
 	rf := NewRunfile()
 	for _, r := range rfs {
@@ -49,13 +70,48 @@ func Merge(rfs ...*Runfile) *Runfile {
 	return rf
 }
 
+// Import is the long form of an import, it is decoded into the source@version
+// form imports are stored in.
+type Import struct {
+	Source  string `yaml:"source" mapstructure:"source"`
+	Version string `yaml:"version" mapstructure:"version"`
+}
+
+func (i Import) String() string {
+	if i.Version == "" {
+		return i.Source
+	}
+	return i.Source + "@" + i.Version
+}
+
+// SplitVersion splits an import into its source and version constraint. The
+// version follows the last @ of the import, unless that @ belongs to the
+// source as in git@github.com:org/repo.git.
+func SplitVersion(uri string) (string, string) {
+	i := strings.LastIndex(uri, "@")
+	if i < 0 || strings.ContainsAny(uri[i+1:], "/:") {
+		return uri, ""
+	}
+	return uri[:i], uri[i+1:]
+}
+
+type Profile struct {
+	Env  map[string]string `yaml:"env" mapstructure:"env"`
+	Vars map[string]string `yaml:"vars" mapstructure:"vars"`
+	Args map[string]string `yaml:"args" mapstructure:"args"`
+}
+
 type Action struct {
-	Description  string            `yaml:"desc" mapstructure:"desc"`
-	Dependencies []string          `yaml:"deps" mapstructure:"deps"`
-	Skip         Skip              `yaml:"skip" mapstructure:"skip"`
-	Vars         map[string]Var    `yaml:"vars" mapstructure:"vars"`
-	Env          map[string]string `yaml:"env"  mapstructure:"env"`
-	Commands     []Command         `yaml:"cmds" mapstructure:"cmds"`
+	Description   string            `yaml:"desc" mapstructure:"desc"`
+	Confirm       string            `yaml:"confirm" mapstructure:"confirm"`
+	Silent        bool              `yaml:"silent" mapstructure:"silent"`
+	Preconditions []Precondition    `yaml:"preconditions" mapstructure:"preconditions"`
+	Dependencies  []string          `yaml:"deps" mapstructure:"deps"`
+	Skip          Skip              `yaml:"skip" mapstructure:"skip"`
+	Args          map[string]Arg    `yaml:"args" mapstructure:"args"`
+	Vars          map[string]Var    `yaml:"vars" mapstructure:"vars"`
+	Env           map[string]EnvVar `yaml:"env"  mapstructure:"env"`
+	Commands      []Command         `yaml:"cmds" mapstructure:"cmds"`
 }
 
 type Skip struct {
@@ -63,13 +119,51 @@ type Skip struct {
 	Message string `yaml:"msg" mapstructure:"msg"`
 }
 
+type Precondition struct {
+	Shell   string `yaml:"shell" mapstructure:"shell"`
+	Expr    string `yaml:"expr" mapstructure:"expr"`
+	Message string `yaml:"msg" mapstructure:"msg"`
+}
+
 type Command struct {
 	Shell  string            `yaml:"shell" mapstructure:"shell"`
 	Action string            `yaml:"action" mapstructure:"action"`
 	Args   map[string]string `yaml:"args" mapstructure:"args"`
+	Silent bool              `yaml:"silent" mapstructure:"silent"`
+}
+
+type Arg struct {
+	Default string   `yaml:"default" mapstructure:"default"`
+	Prompt  string   `yaml:"prompt" mapstructure:"prompt"`
+	Choices []string `yaml:"choices" mapstructure:"choices"`
 }
 
 type Var struct {
-	Value string `yaml:"value"`
-	Shell string `yaml:"shell"`
+	Value   string   `yaml:"value"`
+	Shell   string   `yaml:"shell"`
+	File    string   `yaml:"file"`
+	Env     string   `yaml:"env"`
+	Secret  bool     `yaml:"secret"`
+	Prompt  string   `yaml:"prompt"`
+	Choices []string `yaml:"choices"`
+}
+
+type EnvVar struct {
+	Value  string `yaml:"value" mapstructure:"value"`
+	Shell  string `yaml:"shell" mapstructure:"shell"`
+	File   string `yaml:"file" mapstructure:"file"`
+	Env    string `yaml:"env" mapstructure:"env"`
+	From   string `yaml:"from" mapstructure:"from"`
+	Secret bool   `yaml:"secret" mapstructure:"secret"`
+}
+
+// SortedKeys returns the keys of a map in order, for visiting the maps of a
+// runfile deterministically.
+func SortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
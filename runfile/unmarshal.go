@@ -13,6 +13,9 @@ func Unmarshal(content []byte) (*Runfile, error) {
 	if err := yaml.Unmarshal(content, &a); err != nil {
 		return nil, errors.Wrap(err, "unmarshal runfile")
 	}
+	if err := decodeImports(a); err != nil {
+		return nil, errors.Wrap(err, "decode runfile")
+	}
 
 	var runfile Runfile
 	return &runfile, errors.Wrap(decode(a, &runfile), "decode runfile")
@@ -37,11 +40,51 @@ func decodeHook(fromType, toType reflect.Type, from any) (any, error) {
 		switch toType {
 		case reflect.TypeOf(Skip{}):
 			return Skip{Shell: from.(string)}, nil
+		case reflect.TypeOf(Arg{}):
+			return Arg{Default: from.(string)}, nil
+		case reflect.TypeOf(Precondition{}):
+			return Precondition{Shell: from.(string)}, nil
 		case reflect.TypeOf(Var{}):
 			return Var{Shell: from.(string)}, nil
+		case reflect.TypeOf(EnvVar{}):
+			return EnvVar{Value: from.(string)}, nil
 		case reflect.TypeOf(Command{}):
 			return Command{Shell: from.(string)}, nil
 		}
 	}
 	return from, nil
 }
+
+// decodeImports turns imports in their long form into the source@version form
+// imports are stored in.
+func decodeImports(a any) error {
+	root, ok := a.(map[string]any)
+	if !ok {
+		return nil
+	}
+	imports, ok := root["imports"].(map[string]any)
+	if !ok {
+		return nil
+	}
+	for name, value := range imports {
+		if _, ok := value.(map[string]any); !ok {
+			continue
+		}
+		var imp Import
+		decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+			ErrorUnused: true,
+			Result:      &imp,
+		})
+		if err != nil {
+			return errors.Wrap(err, "error creating decoder")
+		}
+		if err := decoder.Decode(value); err != nil {
+			return errors.Wrapf(err, "import '%s'", name)
+		}
+		if imp.Source == "" {
+			return errors.Errorf("import '%s' has no source", name)
+		}
+		imports[name] = imp.String()
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package runfile
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshal_Imports(t *testing.T) {
+	rf, err := Unmarshal([]byte(`
+imports:
+  go:
+    source: github.com/campbel/run/actions/golang
+    version: ^1.2
+  docker: github.com/campbel/run/actions/docker@v1.0.0
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"go":     "github.com/campbel/run/actions/golang@^1.2",
+		"docker": "github.com/campbel/run/actions/docker@v1.0.0",
+	}, rf.Imports)
+
+	_, err = Unmarshal([]byte("imports:\n  go:\n    version: ^1.2\n"))
+	assert.EqualError(t, err, "decode runfile: import 'go' has no source")
+
+	_, err = Unmarshal([]byte("imports:\n  go:\n    source: github.com/org/go\n    ref: main\n"))
+	assert.ErrorContains(t, err, "ref")
+
+	// Only imports have a long form
+	_, err = Unmarshal([]byte("replace:\n  github.com/org/go:\n    source: ../go\n"))
+	assert.Error(t, err)
+	_, err = Unmarshal([]byte("secrets:\n  token:\n    source: env://TOKEN\n"))
+	assert.Error(t, err)
+}
@@ -2,63 +2,110 @@ package runner
 
 import (
 	"runtime"
-	"strings"
+	"sync"
+	"time"
 
 	"github.com/campbel/run/runfile"
 	"github.com/pkg/errors"
 )
 
 type ActionContext struct {
-	Global       *GlobalContext
-	Package      *PackageContext
-	Dependencies []string
-	Skip         *SkipContext
-	Vars         map[string]*VarContext
-	env          map[string]string
-	Commands     []*CommandContext
+	Global        *GlobalContext
+	Package       *PackageContext
+	Name          string
+	Confirm       string
+	Silent        bool
+	Preconditions []*PreconditionContext
+	Dependencies  []string
+	Skip          *SkipContext
+	Args          map[string]*ArgContext
+	Vars          map[string]*VarContext
+	env           map[string]*EnvContext
+	Commands      []*CommandContext
+
+	secrets     []string
+	envMu       sync.Mutex
+	resolvedEnv map[string]string
 }
 
-func NewActionContext(global *GlobalContext, pkg *PackageContext, action runfile.Action) *ActionContext {
+func NewActionContext(global *GlobalContext, pkg *PackageContext, name string, action runfile.Action) *ActionContext {
 	actionContext := &ActionContext{
 		Global:       global,
 		Package:      pkg,
+		Name:         name,
+		Confirm:      action.Confirm,
+		Silent:       action.Silent,
 		Dependencies: action.Dependencies,
-		env:          action.Env,
 	}
 
+	actionContext.Preconditions = NewPreconditionContexts(actionContext, action.Preconditions)
 	actionContext.Skip = NewSkipContext(actionContext, action.Skip)
+	actionContext.Args = NewArgContexts(actionContext, action.Args)
 	actionContext.Vars = NewVarContexts(actionContext, action.Vars)
 	actionContext.Commands = NewCommandContexts(actionContext, action.Commands)
+	actionContext.env = NewEnvContexts(actionContext, action.Env)
+	actionContext.secrets = secretRefs(actionTemplates(action)...)
 
 	return actionContext
 }
 
+// Run runs the action, the secrets of the run are masked in the error.
 func (ctx *ActionContext) Run(passedArgs map[string]string) error {
-	for _, dep := range ctx.Dependencies {
-		if strings.Contains(dep, ".") {
-			parts := strings.SplitN(dep, ".", 2)
-			pkg, action := parts[0], parts[1]
-			if packageCtx, exists := ctx.Package.Imports[pkg]; exists {
-				if err := packageCtx.Run(action, passedArgs); err != nil {
-					return errors.Wrap(err, "error running action")
-				}
-			} else {
-				return errors.Errorf("no package with the name '%s'", pkg)
-			}
-		} else {
-			if action, exists := ctx.Package.Actions[dep]; exists {
-				if err := action.Run(passedArgs); err != nil {
-					return err
-				}
-			} else {
-				return errors.Errorf("no action with the name '%s'", dep)
-			}
-		}
+	return ctx.Global.secrets.maskError(ctx.run(0, false, false, passedArgs))
+}
+
+// run executes the action as a step of the run started by parent and reports
+// its start and finish to the observers. dep marks actions run as a dependency,
+// silent actions run by a silent action or command.
+func (ctx *ActionContext) run(parent int, dep, silent bool, passedArgs map[string]string) error {
+	id := ctx.Global.nextID()
+	start := time.Now()
+	ctx.Global.emit(Event{
+		Type:    ActionStarted,
+		ID:      id,
+		Parent:  parent,
+		Time:    start,
+		Package: ctx.Package.URI,
+		Action:  ctx.FullName(),
+		Dep:     dep,
+	})
+
+	status, err := ctx.execute(id, silent || ctx.Silent, passedArgs)
+	if err != nil {
+		status = StatusFailed
+	}
+
+	ctx.Global.emit(Event{
+		Type:     ActionFinished,
+		ID:       id,
+		Parent:   parent,
+		Time:     time.Now(),
+		Package:  ctx.Package.URI,
+		Action:   ctx.FullName(),
+		Dep:      dep,
+		Status:   status,
+		Duration: time.Since(start),
+		ExitCode: exitCode(err),
+		Err:      err,
+	})
+	return err
+}
+
+func (ctx *ActionContext) execute(id int, silent bool, passedArgs map[string]string) (Status, error) {
+	// Secrets are only fetched when an action that uses them runs
+	secrets, err := ctx.resolveSecrets(passedArgs)
+	if err != nil {
+		return "", err
 	}
 
 	// Variables cascade
 	// The defaults are input to args
 	// The defaults and args are input to vars
+	// The profile provides args that are not passed and overrides vars
+	// Args and vars that are only prompted for are resolved, checked and
+	// confirmed before the dependencies run so prompts happen up front, other
+	// vars after them so they can use what the dependencies made
+	profile := ctx.Global.profile
 	input := map[string]any{
 		"os":      runtime.GOOS,
 		"OS":      runtime.GOOS,
@@ -66,50 +113,225 @@ func (ctx *ActionContext) Run(passedArgs map[string]string) error {
 		"ARCH":    runtime.GOARCH,
 		"pkg_dir": ctx.Package.Dir,
 		"PKG_DIR": ctx.Package.Dir,
+		"secrets": secrets,
+		"SECRETS": secrets,
+		"profile": profile.Name,
+		"PROFILE": profile.Name,
+		"values":  ctx.Global.values,
+		"VALUES":  ctx.Global.values,
 	}
 
 	args := make(map[string]any)
 	for name, arg := range passedArgs {
 		subbedArg, err := varSub(input, arg)
 		if err != nil {
-			return err
+			return "", err
 		}
 		args[name] = subbedArg
 	}
+	for name, arg := range profile.Args {
+		if _, passed := args[name]; passed {
+			continue
+		}
+		subbedArg, err := varSub(input, arg)
+		if err != nil {
+			return "", err
+		}
+		args[name] = subbedArg
+	}
+	for _, name := range runfile.SortedKeys(ctx.Args) {
+		if _, passed := args[name]; passed {
+			continue
+		}
+		value, err := ctx.Args[name].GetValue(input)
+		if err != nil {
+			return "", errors.Wrapf(err, "error getting value for arg '%s'", name)
+		}
+		args[name] = value
+	}
 	input["args"] = args
 	input["ARGS"] = args
 
+	if err := ctx.resolveEnv(); err != nil {
+		return "", err
+	}
+
+	// Preconditions fail before the dependencies run, they see the args, env
+	// and profile but not the vars
+	if err := checkPreconditions(ctx.FullName(), ctx.Preconditions, input); err != nil {
+		return "", err
+	}
+
 	vars := make(map[string]any)
-	for name, varCtx := range ctx.Vars {
-		value, err := varCtx.GetValue(input)
+	for name, value := range profile.Vars {
+		vars[name] = value
+	}
+	if err := ctx.resolveVars(id, input, vars, true); err != nil {
+		return "", err
+	}
+
+	if ctx.Confirm != "" {
+		question, err := varSub(input, ctx.Confirm)
 		if err != nil {
-			return errors.Wrap(err, "error geting value for var")
+			return "", err
+		}
+		confirmed, err := ctx.Global.confirm(question)
+		if err != nil {
+			return "", err
+		}
+		if !confirmed {
+			return "", errors.Errorf("aborted '%s': %s", ctx.FullName(), question)
+		}
+	}
+
+	for _, dep := range ctx.Dependencies {
+		if err := ctx.Package.runReference(id, true, silent, dep, passedArgs); err != nil {
+			return "", err
 		}
-		vars[name] = value
-		vars[name] = value
+	}
+
+	if err := ctx.resolveVars(id, input, vars, false); err != nil {
+		return "", err
 	}
 	input["vars"] = vars
 	input["VARS"] = vars
 
-	if skip, err := ctx.Skip.Run(input); skip || err != nil {
-		return err
+	if skip, err := ctx.Skip.Run(id, input); skip || err != nil {
+		return StatusSkipped, err
 	}
 
 	for _, cmd := range ctx.Commands {
-		if err := cmd.Run(input); err != nil {
-			return err
+		if err := cmd.Run(id, silent, input); err != nil {
+			return "", err
 		}
 	}
-	return nil
+	return StatusOK, nil
+}
+
+// FullName returns the action name qualified by the name its package is
+// imported as, e.g. go.install_pkg.
+func (ctx *ActionContext) FullName() string {
+	if ctx.Package.Name == "" {
+		return ctx.Name
+	}
+	return ctx.Package.Name + "." + ctx.Name
 }
 
 func (ctx *ActionContext) Env() map[string]string {
+	ctx.envMu.Lock()
+	defer ctx.envMu.Unlock()
 	merged := make(map[string]string)
 	for name, value := range ctx.Package.Env() {
 		merged[name] = value
 	}
-	for name, value := range ctx.env {
+	for name, value := range ctx.resolvedEnv {
+		merged[name] = value
+	}
+	for name, value := range ctx.Global.profile.Env {
 		merged[name] = value
 	}
 	return merged
 }
+
+// resolveVars resolves the vars that are only prompted for, or all others,
+// into vars. Vars the profile overrides are not resolved but stay secret.
+func (ctx *ActionContext) resolveVars(id int, input map[string]any, vars map[string]any, prompts bool) error {
+	profile := ctx.Global.profile
+	for _, name := range runfile.SortedKeys(ctx.Vars) {
+		if ctx.Vars[name].promptOnly() != prompts {
+			continue
+		}
+		if value, ok := profile.Vars[name]; ok {
+			if ctx.Vars[name].Secret {
+				ctx.Global.secrets.add(value)
+			}
+			continue
+		}
+		value, err := ctx.Vars[name].GetValue(id, input)
+		if err != nil {
+			return errors.Wrap(err, "error geting value for var")
+		}
+		vars[name] = value
+	}
+	return nil
+}
+
+// resolveEnv reads the env entries of the action the first time it runs,
+// secret values are masked in all output from then on.
+func (ctx *ActionContext) resolveEnv() error {
+	ctx.envMu.Lock()
+	defer ctx.envMu.Unlock()
+	if ctx.resolvedEnv != nil {
+		return nil
+	}
+
+	// Shell entries see the env of the profile, entries it overrides are not
+	// resolved but stay secret
+	profile := ctx.Global.profile
+	env := make(map[string]string)
+	for name, value := range ctx.Package.Env() {
+		env[name] = value
+	}
+	for name, value := range profile.Env {
+		env[name] = value
+	}
+	resolved := make(map[string]string)
+	for _, name := range runfile.SortedKeys(ctx.env) {
+		if value, ok := profile.Env[name]; ok {
+			if ctx.env[name].Secret || ctx.env[name].From != "" {
+				ctx.Global.secrets.add(value)
+			}
+			continue
+		}
+		value, err := ctx.env[name].GetValue(env)
+		if err != nil {
+			return errors.Wrapf(err, "error getting value for env '%s'", name)
+		}
+		if ctx.env[name].Secret {
+			ctx.Global.secrets.add(value)
+		}
+		env[name] = value
+		resolved[name] = value
+	}
+	ctx.resolvedEnv = resolved
+	return nil
+}
+
+// resolveSecrets fetches the secrets referenced by the action and the args
+// passed to it.
+func (ctx *ActionContext) resolveSecrets(passedArgs map[string]string) (map[string]any, error) {
+	names := append([]string{}, ctx.secrets...)
+	for _, name := range runfile.SortedKeys(passedArgs) {
+		names = append(names, secretRefs(passedArgs[name])...)
+	}
+	secrets := make(map[string]any)
+	for _, name := range names {
+		value, err := ctx.Global.secret(name)
+		if err != nil {
+			return nil, err
+		}
+		secrets[name] = value
+	}
+	return secrets, nil
+}
+
+// actionTemplates lists every template of an action.
+func actionTemplates(action runfile.Action) []string {
+	templates := []string{action.Confirm, action.Skip.Shell}
+	for _, precondition := range action.Preconditions {
+		templates = append(templates, precondition.Shell, precondition.Expr)
+	}
+	for _, arg := range action.Args {
+		templates = append(templates, arg.Default)
+	}
+	for _, v := range action.Vars {
+		templates = append(templates, v.Shell)
+	}
+	for _, command := range action.Commands {
+		templates = append(templates, command.Shell)
+		for _, arg := range command.Args {
+			templates = append(templates, arg)
+		}
+	}
+	return templates
+}
@@ -0,0 +1,60 @@
+package runner
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionContext_DependenciesRunFirst(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "version")
+
+	var out bytes.Buffer
+	global := NewGlobalContext().
+		WithStdout(&out).
+		WithErrout(&bytes.Buffer{}).
+		WithStdin(strings.NewReader("y\n")).
+		WithInteractive(true)
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	pkg.Actions["generate"] = NewActionContext(global, pkg, "generate", runfile.Action{
+		Commands: []runfile.Command{{Shell: "echo 1.2.3 > " + file}},
+	})
+	build := NewActionContext(global, pkg, "build", runfile.Action{
+		Confirm:      "Build {{ .ARGS.TARGET }}?",
+		Dependencies: []string{"generate"},
+		Args:         map[string]runfile.Arg{"TARGET": {Default: "app"}},
+		Vars:         map[string]runfile.Var{"VERSION": {Shell: "cat " + file}},
+		Commands:     []runfile.Command{{Shell: "echo building {{ .ARGS.TARGET }} {{ .VARS.VERSION }}"}},
+	})
+
+	assert.NoError(t, build.Run(nil))
+	assert.Equal(t, "building app 1.2.3\n", out.String())
+}
+
+func TestActionContext_PromptsBeforeDependencies(t *testing.T) {
+	var out bytes.Buffer
+	global := NewGlobalContext().
+		WithStdout(&out).
+		WithErrout(&out).
+		WithStdin(strings.NewReader("eu\n")).
+		WithInteractive(true)
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	pkg.Actions["install"] = NewActionContext(global, pkg, "install", runfile.Action{
+		Commands: []runfile.Command{{Shell: "echo installing"}},
+	})
+	deploy := NewActionContext(global, pkg, "deploy", runfile.Action{
+		Dependencies: []string{"install"},
+		Vars: map[string]runfile.Var{
+			"REGION":  {Prompt: "Region?"},
+			"VERSION": {Shell: "echo 1.2.3"},
+		},
+		Commands: []runfile.Command{{Shell: "echo deploying {{ .VARS.VERSION }} to {{ .VARS.REGION }}"}},
+	})
+
+	assert.NoError(t, deploy.Run(nil))
+	assert.Equal(t, "Region? installing\ndeploying 1.2.3 to eu\n", out.String())
+}
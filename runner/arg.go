@@ -0,0 +1,46 @@
+package runner
+
+import (
+	"github.com/campbel/run/runfile"
+	"github.com/pkg/errors"
+)
+
+type ArgContext struct {
+	actionContext *ActionContext
+	Default       string
+	Prompt        string
+	Choices       []string
+}
+
+func NewArgContexts(actionContext *ActionContext, args map[string]runfile.Arg) map[string]*ArgContext {
+	contexts := make(map[string]*ArgContext)
+	for name, arg := range args {
+		contexts[name] = NewArgContext(actionContext, arg)
+	}
+	return contexts
+}
+
+func NewArgContext(actionContext *ActionContext, arg runfile.Arg) *ArgContext {
+	return &ArgContext{
+		actionContext: actionContext,
+		Default:       arg.Default,
+		Prompt:        arg.Prompt,
+		Choices:       arg.Choices,
+	}
+}
+
+// GetValue returns the value for an arg that was not passed, either from its
+// default or by prompting the user.
+func (ctx *ArgContext) GetValue(input any) (string, error) {
+	if ctx.Default != "" {
+		value, err := varSub(input, ctx.Default)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to substitute default")
+		}
+		return value, nil
+	}
+	if ctx.Prompt != "" {
+		return ctx.actionContext.Global.prompt(ctx.Prompt, ctx.Choices)
+	}
+	return "", nil
+}
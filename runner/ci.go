@@ -0,0 +1,125 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type CI string
+
+const (
+	CINone   CI = "none"
+	CIGitHub CI = "github"
+	CIGitLab CI = "gitlab"
+)
+
+func ParseCI(ci string) (CI, error) {
+	switch CI(ci) {
+	case CINone, CIGitHub, CIGitLab:
+		return CI(ci), nil
+	}
+	return "", errors.Errorf("unknown ci '%s', expected github, gitlab or none", ci)
+}
+
+// DetectCI recognizes the CI platform from the environment it sets.
+func DetectCI() CI {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return CIGitHub
+	case os.Getenv("GITLAB_CI") == "true":
+		return CIGitLab
+	}
+	return CINone
+}
+
+// WithCI wraps the output of every action in the collapsible groups of the CI
+// platform and annotates failures.
+func (c *GlobalContext) WithCI(ci CI) *GlobalContext {
+	if ci == CIGitHub || ci == CIGitLab {
+		c.observers = append(c.observers, &ciDecorator{
+			global:   c,
+			ci:       ci,
+			failures: make(failures),
+		})
+	}
+	return c
+}
+
+// ciDecorator opens a group when an action starts a command and closes it when
+// the action finishes or another action takes over the output. Groups never
+// nest, as GitHub does not support it.
+type ciDecorator struct {
+	global   *GlobalContext
+	ci       CI
+	group    int
+	failures failures
+}
+
+func (d *ciDecorator) Observe(event Event) {
+	switch event.Type {
+	case CommandStarted:
+		if d.group != event.Parent {
+			d.end()
+			d.start(event.Parent, event.Action)
+		}
+	case CommandExited:
+		if event.Err != nil && d.failures.first(event) {
+			d.end()
+			d.annotate(event.Action, fmt.Sprintf("command failed with exit code %d: %s", event.ExitCode, event.Command))
+		}
+	case ActionFinished:
+		if d.group == event.ID {
+			d.end()
+		}
+		if event.Err != nil && d.failures.first(event) {
+			d.annotate(event.Action, event.Err.Error())
+		}
+	}
+}
+
+func (d *ciDecorator) start(id int, name string) {
+	d.global.writeMu.Lock()
+	defer d.global.writeMu.Unlock()
+	d.group = id
+	switch d.ci {
+	case CIGitHub:
+		fmt.Fprintf(d.global.out, "::group::%s\n", name)
+	case CIGitLab:
+		fmt.Fprintf(d.global.out, "\x1b[0Ksection_start:%d:action_%d[collapsed=true]\r\x1b[0K%s\n", time.Now().Unix(), id, name)
+	}
+}
+
+func (d *ciDecorator) end() {
+	if d.group == 0 {
+		return
+	}
+	d.global.writeMu.Lock()
+	defer d.global.writeMu.Unlock()
+	switch d.ci {
+	case CIGitHub:
+		fmt.Fprintln(d.global.out, "::endgroup::")
+	case CIGitLab:
+		fmt.Fprintf(d.global.out, "\x1b[0Ksection_end:%d:action_%d\r\x1b[0K\n", time.Now().Unix(), d.group)
+	}
+	d.group = 0
+}
+
+func (d *ciDecorator) annotate(action, message string) {
+	d.global.writeMu.Lock()
+	defer d.global.writeMu.Unlock()
+	switch d.ci {
+	case CIGitHub:
+		fmt.Fprintf(d.global.err, "::error title=%s::%s\n", githubProperty.Replace(action), githubData.Replace(message))
+	case CIGitLab:
+		fmt.Fprintf(d.global.err, "\x1b[31;1mERROR: [%s] %s\x1b[0m\n", action, message)
+	}
+}
+
+var (
+	githubData     = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A")
+	githubProperty = strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C")
+)
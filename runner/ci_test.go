@@ -0,0 +1,45 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobalContext_WithCI(t *testing.T) {
+	var out bytes.Buffer
+	global := NewGlobalContext().WithStdout(&out).WithErrout(&out).WithCI(CIGitHub)
+
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	pkg.Actions["echo"] = NewActionContext(global, pkg, "echo", runfile.Action{
+		Commands: []runfile.Command{{Shell: "echo hello"}},
+	})
+	pkg.Actions["check"] = NewActionContext(global, pkg, "check", runfile.Action{
+		Preconditions: []runfile.Precondition{{Shell: "false", Message: "docker must be running"}},
+	})
+	pkg.Actions["build"] = NewActionContext(global, pkg, "build", runfile.Action{
+		Commands: []runfile.Command{
+			{Shell: "echo building"},
+			{Action: "echo"},
+			{Shell: "exit 2"},
+		},
+	})
+
+	assert.Error(t, pkg.Run("build", nil))
+	assert.Equal(t, `::group::build
+building
+::endgroup::
+::group::echo
+hello
+::endgroup::
+::group::build
+::endgroup::
+::error title=build::command failed with exit code 2: exit 2
+`, out.String())
+
+	out.Reset()
+	assert.Error(t, pkg.Run("check", nil))
+	assert.Equal(t, "::error title=check::preconditions failed for 'check':%0A  - docker must be running\n", out.String())
+}
@@ -1,11 +1,12 @@
 package runner
 
 import (
+	"bytes"
+	"io"
 	"os/exec"
-	"strings"
+	"time"
 
 	"github.com/campbel/run/runfile"
-	"github.com/pkg/errors"
 )
 
 type CommandContext struct {
@@ -14,6 +15,7 @@ type CommandContext struct {
 	Action string
 	Shell  string
 	Args   map[string]string
+	Silent bool
 }
 
 func NewCommandContexts(actionCtx *ActionContext, commands []runfile.Command) []*CommandContext {
@@ -31,49 +33,84 @@ func NewCommandContext(actionCtx *ActionContext, command runfile.Command) *Comma
 		Action: command.Action,
 		Shell:  command.Shell,
 		Args:   command.Args,
+		Silent: command.Silent,
 	}
 }
 
-func (cmd *CommandContext) Run(input map[string]any) error {
+// Run executes the command as part of the action run identified by parent.
+// Silent commands, and every command of a silent action, hold their stdout
+// back, including the commands of the actions they run.
+func (cmd *CommandContext) Run(parent int, silent bool, input map[string]any) error {
+	silent = silent || cmd.Silent
 	if cmd.Shell != "" {
 		subbedCommand, err := varSub(input, cmd.Shell)
 		if err != nil {
 			return err
 		}
-		command := exec.Command("sh", "-c", subbedCommand)
-		command.Env = commandEnv(cmd.actionContext.Env())
-		command.Stdout = cmd.actionContext.Global.out
-		command.Stderr = cmd.actionContext.Global.err
-		command.Stdin = cmd.actionContext.Global.in
-		if err := command.Run(); err != nil {
-			return err
-		}
-		return nil
+		return cmd.runShell(parent, silent, subbedCommand)
 	}
 	if cmd.Action != "" {
-		if strings.Contains(cmd.Action, ".") {
-			parts := strings.SplitN(cmd.Action, ".", 2)
-			pkg, action := parts[0], parts[1]
-			if packageCtx, exists := cmd.actionContext.Package.Imports[pkg]; exists {
-				if err := packageCtx.Run(action, cmd.Args); err != nil {
-					return errors.Wrap(err, "error running action")
-				}
-			} else {
-				return errors.Errorf("no package with the name '%s'", pkg)
-			}
-		} else {
-			if action, exists := cmd.actionContext.Package.Actions[cmd.Action]; exists {
-				if err := action.Run(cmd.Args); err != nil {
-					return err
-				}
-			} else {
-				return errors.Errorf("no action with the name '%s'", cmd.Action)
-			}
-		}
+		return cmd.actionContext.Package.runReference(parent, false, silent, cmd.Action, cmd.Args)
 	}
 	return nil
 }
 
+func (cmd *CommandContext) runShell(parent int, silent bool, subbedCommand string) error {
+	global := cmd.actionContext.Global
+	id := global.nextID()
+	start := time.Now()
+	global.emit(Event{
+		Type:    CommandStarted,
+		ID:      id,
+		Parent:  parent,
+		Time:    start,
+		Package: cmd.actionContext.Package.URI,
+		Action:  cmd.actionContext.FullName(),
+		Command: subbedCommand,
+	})
+
+	command := exec.Command("sh", "-c", subbedCommand)
+	command.Env = commandEnv(cmd.actionContext.Env())
+	stdout, errout, flush := global.commandOutput(cmd.actionContext.FullName())
+	command.Stdout = stdout
+	command.Stderr = errout
+	command.Stdin = global.in
+	// Silent commands hold their stdout back and only show it on failure
+	var silenced bytes.Buffer
+	if silent || global.quiet {
+		command.Stdout = &silenced
+	}
+	var stderr bytes.Buffer
+	if global.captureStderr {
+		command.Stderr = io.MultiWriter(errout, &stderr)
+	}
+	err := command.Run()
+	if err != nil && silenced.Len() > 0 {
+		stdout.Write(silenced.Bytes())
+	}
+	flush()
+
+	status := StatusOK
+	if err != nil {
+		status = StatusFailed
+	}
+	global.emit(Event{
+		Type:     CommandExited,
+		ID:       id,
+		Parent:   parent,
+		Time:     time.Now(),
+		Package:  cmd.actionContext.Package.URI,
+		Action:   cmd.actionContext.FullName(),
+		Command:  subbedCommand,
+		Stderr:   stderr.String(),
+		Status:   status,
+		Duration: time.Since(start),
+		ExitCode: exitCode(err),
+		Err:      err,
+	})
+	return err
+}
+
 func commandEnv(env map[string]string) []string {
 	var envs []string
 	for key, value := range env {
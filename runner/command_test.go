@@ -0,0 +1,73 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCommandContext_Silent(t *testing.T) {
+	newPackage := func(global *GlobalContext) *PackageContext {
+		pkg := NewPackageContext(global, runfile.NewRunfile())
+		pkg.Actions["setup"] = NewActionContext(global, pkg, "setup", runfile.Action{
+			Commands: []runfile.Command{{Shell: "echo setting up"}},
+		})
+		pkg.Actions["install"] = NewActionContext(global, pkg, "install", runfile.Action{
+			Silent:       true,
+			Dependencies: []string{"setup"},
+			Commands: []runfile.Command{
+				{Shell: "echo installing"},
+				{Action: "setup"},
+			},
+		})
+		pkg.Actions["build"] = NewActionContext(global, pkg, "build", runfile.Action{
+			Dependencies: []string{"install"},
+			Commands: []runfile.Command{
+				{Shell: "echo generating", Silent: true},
+				{Action: "setup", Silent: true},
+				{Shell: "echo building"},
+			},
+		})
+		pkg.Actions["deploy"] = NewActionContext(global, pkg, "deploy", runfile.Action{
+			Commands: []runfile.Command{{Action: "setup"}},
+		})
+		pkg.Actions["broken"] = NewActionContext(global, pkg, "broken", runfile.Action{
+			Commands: []runfile.Command{{Shell: "echo compiling; exit 1", Silent: true}},
+		})
+		return pkg
+	}
+
+	t.Run("hides output of silent actions and commands", func(t *testing.T) {
+		var out bytes.Buffer
+		pkg := newPackage(NewGlobalContext().WithStdout(&out))
+
+		assert.NoError(t, pkg.Run("build", nil))
+		assert.Equal(t, "building\n", out.String())
+	})
+
+	t.Run("shows output of actions run by commands that are not silent", func(t *testing.T) {
+		var out bytes.Buffer
+		pkg := newPackage(NewGlobalContext().WithStdout(&out))
+
+		assert.NoError(t, pkg.Run("deploy", nil))
+		assert.Equal(t, "setting up\n", out.String())
+	})
+
+	t.Run("dumps output on failure", func(t *testing.T) {
+		var out bytes.Buffer
+		pkg := newPackage(NewGlobalContext().WithStdout(&out))
+
+		assert.Error(t, pkg.Run("broken", nil))
+		assert.Equal(t, "compiling\n", out.String())
+	})
+
+	t.Run("quiet hides everything", func(t *testing.T) {
+		var out bytes.Buffer
+		pkg := newPackage(NewGlobalContext().WithStdout(&out).WithQuiet(true))
+
+		assert.NoError(t, pkg.Run("build", nil))
+		assert.Empty(t, out.String())
+	})
+}
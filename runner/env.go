@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"github.com/campbel/run/runfile"
+	"github.com/pkg/errors"
+)
+
+type EnvContext struct {
+	actionContext *ActionContext
+	Value         string
+	Shell         string
+	File          string
+	Env           string
+	From          string
+	Secret        bool
+}
+
+func NewEnvContexts(actionContext *ActionContext, env map[string]runfile.EnvVar) map[string]*EnvContext {
+	contexts := make(map[string]*EnvContext)
+	for name, envVar := range env {
+		contexts[name] = NewEnvContext(actionContext, envVar)
+	}
+	return contexts
+}
+
+func NewEnvContext(actionContext *ActionContext, envVar runfile.EnvVar) *EnvContext {
+	return &EnvContext{
+		actionContext: actionContext,
+		Value:         envVar.Value,
+		Shell:         envVar.Shell,
+		File:          envVar.File,
+		Env:           envVar.Env,
+		From:          envVar.From,
+		Secret:        envVar.Secret,
+	}
+}
+
+// GetValue resolves the entry, a shell command runs with the given environment.
+// Entries from a secret of the runfile are fetched through its provider.
+func (ctx *EnvContext) GetValue(env map[string]string) (string, error) {
+	if ctx.From != "" {
+		return ctx.actionContext.Global.secret(ctx.From)
+	}
+	if value, ok, err := readSource(ctx.File, ctx.Env); ok {
+		return value, err
+	}
+	if ctx.Shell != "" {
+		command := exec.Command("sh", "-c", ctx.Shell)
+		command.Env = commandEnv(env)
+		var buffer bytes.Buffer
+		command.Stdout = &buffer
+		if err := command.Run(); err != nil {
+			return "", errors.Wrap(err, "failed to run shell command")
+		}
+		return strings.TrimSpace(buffer.String()), nil
+	}
+	return ctx.Value, nil
+}
@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"encoding/json"
+	"io"
+	"os/exec"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Status is the outcome of a step of a run. There is no cached status, every
+// action runs each time it is referenced since actions have no outputs to key
+// a cache on.
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusSkipped Status = "skipped"
+	StatusFailed  Status = "failed"
+)
+
+type EventType string
+
+const (
+	ActionStarted  EventType = "action_start"
+	ActionFinished EventType = "action_finish"
+	ActionSkipped  EventType = "action_skip"
+	CommandStarted EventType = "command_start"
+	CommandExited  EventType = "command_exit"
+	VarStarted     EventType = "var_start"
+	VarFinished    EventType = "var_finish"
+	SkipStarted    EventType = "skip_check_start"
+	SkipFinished   EventType = "skip_check_finish"
+)
+
+// Event describes a step of a run. Every started step gets an ID unique to the
+// run, and Parent is the ID of the step that caused it, or 0 for the root.
+// Finish and skip events carry the ID of the step they complete. Value is the
+// resolved value of a var, it is kept out of the JSON encoding.
+type Event struct {
+	Type     EventType
+	ID       int
+	Parent   int
+	Time     time.Time
+	Package  string
+	Action   string
+	Dep      bool
+	Var      string
+	Value    string
+	Command  string
+	Message  string
+	Stderr   string
+	Status   Status
+	Duration time.Duration
+	ExitCode int
+	Err      error
+}
+
+// MarshalJSON encodes the event with the error as its message and the
+// duration in milliseconds. Var values are left out so event streams never
+// carry them.
+func (e Event) MarshalJSON() ([]byte, error) {
+	var errMessage string
+	if e.Err != nil {
+		errMessage = e.Err.Error()
+	}
+	return json.Marshal(struct {
+		Type     EventType `json:"type"`
+		ID       int       `json:"id"`
+		Parent   int       `json:"parent,omitempty"`
+		Time     time.Time `json:"time"`
+		Package  string    `json:"package,omitempty"`
+		Action   string    `json:"action,omitempty"`
+		Dep      bool      `json:"dep,omitempty"`
+		Var      string    `json:"var,omitempty"`
+		Command  string    `json:"command,omitempty"`
+		Message  string    `json:"message,omitempty"`
+		Stderr   string    `json:"stderr,omitempty"`
+		Status   Status    `json:"status,omitempty"`
+		Duration float64   `json:"duration_ms,omitempty"`
+		ExitCode int       `json:"exit_code,omitempty"`
+		Error    string    `json:"error,omitempty"`
+	}{
+		Type:     e.Type,
+		ID:       e.ID,
+		Parent:   e.Parent,
+		Time:     e.Time,
+		Package:  e.Package,
+		Action:   e.Action,
+		Dep:      e.Dep,
+		Var:      e.Var,
+		Command:  e.Command,
+		Message:  e.Message,
+		Stderr:   e.Stderr,
+		Status:   e.Status,
+		Duration: float64(e.Duration) / float64(time.Millisecond),
+		ExitCode: e.ExitCode,
+		Error:    errMessage,
+	})
+}
+
+// Observer receives the events of a run in the order they happen.
+type Observer interface {
+	Observe(Event)
+}
+
+// failures tracks the steps of a run with a failed nested step, so observers
+// report a failure where it happened and not again for every parent.
+type failures map[int]bool
+
+// first records the failure of the step the event finishes and reports
+// whether it failed itself rather than because of a nested step.
+func (f failures) first(event Event) bool {
+	first := !f[event.ID]
+	f[event.Parent] = true
+	return first
+}
+
+// JSONEvents is an Observer that writes every event as a line of JSON. Events
+// after a failed write are dropped, Err returns the failure.
+type JSONEvents struct {
+	encoder *json.Encoder
+	err     error
+}
+
+func NewJSONEvents(w io.Writer) *JSONEvents {
+	return &JSONEvents{encoder: json.NewEncoder(w)}
+}
+
+func (j *JSONEvents) Observe(event Event) {
+	if j.err != nil {
+		return
+	}
+	if err := j.encoder.Encode(event); err != nil {
+		j.err = errors.Wrap(err, "failed to write event")
+	}
+}
+
+// Err returns the first error writing an event.
+func (j *JSONEvents) Err() error {
+	return j.err
+}
+
+func (c *GlobalContext) nextID() int {
+	return int(c.lastID.Add(1))
+}
+
+func (c *GlobalContext) emit(event Event) {
+	if len(c.observers) == 0 {
+		return
+	}
+	event = c.secrets.maskEvent(event)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, observer := range c.observers {
+		observer.Observe(event)
+	}
+}
+
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return 1
+}
@@ -0,0 +1,87 @@
+package runner
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONEvents(t *testing.T) {
+	var stream bytes.Buffer
+	events := NewJSONEvents(&stream)
+	global := NewGlobalContext().WithStdout(&bytes.Buffer{}).WithObserver(events)
+	pkg := NewPackageContext(global, runfile.NewRunfile()).WithURI("github.com/org/app")
+	pkg.Actions["install"] = NewActionContext(global, pkg, "install", runfile.Action{
+		Skip: runfile.Skip{Shell: "true", Message: "installed"},
+	})
+	pkg.Actions["build"] = NewActionContext(global, pkg, "build", runfile.Action{
+		Dependencies: []string{"install"},
+		Commands:     []runfile.Command{{Shell: "echo {{ .ARGS.NAME }}; exit 2"}},
+	})
+
+	assert.Error(t, pkg.Run("build", map[string]string{"NAME": "app"}))
+	assert.NoError(t, events.Err())
+
+	type line struct {
+		Type     EventType `json:"type"`
+		ID       int       `json:"id"`
+		Parent   int       `json:"parent"`
+		Package  string    `json:"package"`
+		Action   string    `json:"action"`
+		Dep      bool      `json:"dep"`
+		Command  string    `json:"command"`
+		Message  string    `json:"message"`
+		Status   Status    `json:"status"`
+		ExitCode int       `json:"exit_code"`
+		Error    string    `json:"error"`
+	}
+	var lines []line
+	scanner := bufio.NewScanner(&stream)
+	for scanner.Scan() {
+		var l line
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &l))
+		assert.Equal(t, "github.com/org/app", l.Package)
+		l.Package = ""
+		lines = append(lines, l)
+	}
+
+	assert.Equal(t, []line{
+		{Type: ActionStarted, ID: 1, Action: "build"},
+		{Type: ActionStarted, ID: 2, Parent: 1, Action: "install", Dep: true},
+		{Type: SkipStarted, ID: 3, Parent: 2, Action: "install", Command: "true"},
+		{Type: SkipFinished, ID: 3, Parent: 2, Action: "install", Command: "true", Status: StatusSkipped},
+		{Type: ActionSkipped, ID: 2, Action: "install", Message: "installed"},
+		{Type: ActionFinished, ID: 2, Parent: 1, Action: "install", Dep: true, Status: StatusSkipped},
+		{Type: CommandStarted, ID: 4, Parent: 1, Action: "build", Command: "echo app; exit 2"},
+		{Type: CommandExited, ID: 4, Parent: 1, Action: "build", Command: "echo app; exit 2", Status: StatusFailed, ExitCode: 2, Error: "exit status 2"},
+		{Type: ActionFinished, ID: 1, Action: "build", Status: StatusFailed, ExitCode: 2, Error: "exit status 2"},
+	}, lines)
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestJSONEvents_Err(t *testing.T) {
+	events := NewJSONEvents(failingWriter{})
+	events.Observe(Event{Type: ActionStarted, ID: 1})
+	events.Observe(Event{Type: ActionFinished, ID: 1})
+	assert.EqualError(t, events.Err(), "failed to write event: disk full")
+}
+
+func TestFailures(t *testing.T) {
+	f := make(failures)
+	// A command of action 2, run by action 1, fails both actions
+	assert.True(t, f.first(Event{ID: 3, Parent: 2}))
+	assert.False(t, f.first(Event{ID: 2, Parent: 1}))
+	assert.False(t, f.first(Event{ID: 1}))
+	// A failure of its own in another action is reported
+	assert.True(t, f.first(Event{ID: 4, Parent: 1}))
+}
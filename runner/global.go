@@ -3,20 +3,54 @@ package runner
 import (
 	"io"
 	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/campbel/run/runfile"
 )
 
 type GlobalContext struct {
 	out io.Writer
 	err io.Writer
 	in  io.Reader
+
+	interactive   bool
+	assumeYes     bool
+	skipOutput    bool
+	captureStderr bool
+	output        OutputMode
+	quiet         bool
+	color         bool
+
+	profile      Profile
+	values       map[string]any
+	secrets      secretSet
+	providers    map[string]SecretProvider
+	secretRefs   map[string]string
+	secretMu     sync.Mutex
+	secretValues map[string]string
+
+	writeMu   sync.Mutex
+	mu        sync.Mutex
+	lastID    atomic.Int64
+	observers []Observer
 }
 
 func NewGlobalContext() *GlobalContext {
-	return &GlobalContext{
-		out: os.Stdout,
-		err: os.Stderr,
-		in:  os.Stdin,
+	c := &GlobalContext{
+		out:          os.Stdout,
+		err:          os.Stderr,
+		in:           os.Stdin,
+		interactive:  isTerminal(os.Stdin),
+		output:       OutputRaw,
+		providers:    make(map[string]SecretProvider),
+		secretValues: make(map[string]string),
 	}
+	c.providers["env"] = EnvSecretProvider{}
+	c.providers["file"] = NewFileSecretProvider(func() (string, error) {
+		return c.SecretsPassphrase(false)
+	})
+	return c
 }
 
 func (c *GlobalContext) WithStdout(out io.Writer) *GlobalContext {
@@ -31,5 +65,77 @@ func (c *GlobalContext) WithErrout(err io.Writer) *GlobalContext {
 
 func (c *GlobalContext) WithStdin(in io.Reader) *GlobalContext {
 	c.in = in
+	c.interactive = isTerminal(in)
+	return c
+}
+
+// WithInteractive overrides the terminal detection done on stdin.
+func (c *GlobalContext) WithInteractive(interactive bool) *GlobalContext {
+	c.interactive = interactive
+	return c
+}
+
+// Profile is a set of values selected for a run, applied to every action.
+type Profile struct {
+	Name string
+	Env  map[string]string
+	Vars map[string]string
+	Args map[string]string
+}
+
+// WithProfile applies the profile to every action, including those of imported
+// packages. Its env overrides package and action env, its vars override the
+// vars of actions and its args are used when an arg is not passed.
+func (c *GlobalContext) WithProfile(name string, profile runfile.Profile) *GlobalContext {
+	c.profile = Profile{
+		Name: name,
+		Env:  profile.Env,
+		Vars: profile.Vars,
+		Args: profile.Args,
+	}
+	return c
+}
+
+// WithOutputMode sets how the output of commands is written.
+func (c *GlobalContext) WithOutputMode(mode OutputMode) *GlobalContext {
+	c.output = mode
+	return c
+}
+
+// WithQuiet silences the stdout of every command unless it fails.
+func (c *GlobalContext) WithQuiet(quiet bool) *GlobalContext {
+	c.quiet = quiet
+	return c
+}
+
+// WithColor colors the action prefix of interleaved output.
+func (c *GlobalContext) WithColor(color bool) *GlobalContext {
+	c.color = color
+	return c
+}
+
+// WithObserver registers an observer for the events of every run.
+func (c *GlobalContext) WithObserver(observer Observer) *GlobalContext {
+	c.observers = append(c.observers, observer)
+	return c
+}
+
+// WithCapturedStderr keeps a copy of the stderr of every command on its exit
+// event, for reports that include it.
+func (c *GlobalContext) WithCapturedStderr(capture bool) *GlobalContext {
+	c.captureStderr = capture
+	return c
+}
+
+// WithSkipOutput shows the output of skip checks, which is discarded by default.
+func (c *GlobalContext) WithSkipOutput(skipOutput bool) *GlobalContext {
+	c.skipOutput = skipOutput
+	return c
+}
+
+// WithAssumeYes accepts every confirmation and disables prompting, so missing
+// values fail fast instead of waiting for input.
+func (c *GlobalContext) WithAssumeYes(assumeYes bool) *GlobalContext {
+	c.assumeYes = assumeYes
 	return c
 }
@@ -0,0 +1,151 @@
+package runner
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"time"
+)
+
+// JUnitReport is an Observer that collects a run into a JUnit XML report, with
+// a testsuite for every action run and a testcase for each of its commands.
+type JUnitReport struct {
+	suites   []*junitTestSuite
+	index    map[int]*junitTestSuite
+	failures failures
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name          `xml:"testsuites"`
+	Tests    int               `xml:"tests,attr"`
+	Failures int               `xml:"failures,attr"`
+	Skipped  int               `xml:"skipped,attr"`
+	Suites   []*junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string           `xml:"name,attr"`
+	Package   string           `xml:"package,attr,omitempty"`
+	Tests     int              `xml:"tests,attr"`
+	Failures  int              `xml:"failures,attr"`
+	Skipped   int              `xml:"skipped,attr"`
+	Time      string           `xml:"time,attr"`
+	Timestamp string           `xml:"timestamp,attr"`
+	Cases     []*junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Skipped   *junitSkipped `xml:"skipped,omitempty"`
+	SystemErr string        `xml:"system-err,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+func NewJUnitReport() *JUnitReport {
+	return &JUnitReport{
+		index:    make(map[int]*junitTestSuite),
+		failures: make(failures),
+	}
+}
+
+func (r *JUnitReport) Observe(event Event) {
+	switch event.Type {
+	case ActionStarted:
+		suite := &junitTestSuite{
+			Name:      event.Action,
+			Package:   event.Package,
+			Timestamp: event.Time.Format(time.RFC3339),
+		}
+		r.suites = append(r.suites, suite)
+		r.index[event.ID] = suite
+	case ActionSkipped:
+		if suite, ok := r.index[event.ID]; ok {
+			suite.add(&junitTestCase{
+				Name:      "skip",
+				Classname: event.Action,
+				Time:      seconds(0),
+				Skipped:   &junitSkipped{Message: event.Message},
+			})
+		}
+	case CommandExited:
+		if suite, ok := r.index[event.Parent]; ok {
+			testCase := &junitTestCase{
+				Name:      event.Command,
+				Classname: event.Action,
+				Time:      seconds(event.Duration),
+				SystemErr: event.Stderr,
+			}
+			if event.Err != nil && r.failures.first(event) {
+				testCase.Failure = &junitFailure{
+					Message: fmt.Sprintf("exit code %d", event.ExitCode),
+					Text:    event.Err.Error(),
+				}
+			}
+			suite.add(testCase)
+		}
+	case ActionFinished:
+		suite, ok := r.index[event.ID]
+		if !ok {
+			return
+		}
+		suite.Time = seconds(event.Duration)
+		if event.Err != nil && r.failures.first(event) {
+			suite.add(&junitTestCase{
+				Name:      event.Action,
+				Classname: event.Action,
+				Time:      seconds(event.Duration),
+				Failure: &junitFailure{
+					Message: "action failed",
+					Text:    event.Err.Error(),
+				},
+			})
+		}
+	}
+}
+
+// Write encodes the report as JUnit XML.
+func (r *JUnitReport) Write(w io.Writer) error {
+	report := junitTestSuites{Suites: r.suites}
+	for _, suite := range r.suites {
+		report.Tests += suite.Tests
+		report.Failures += suite.Failures
+		report.Skipped += suite.Skipped
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func (s *junitTestSuite) add(testCase *junitTestCase) {
+	s.Cases = append(s.Cases, testCase)
+	s.Tests++
+	if testCase.Failure != nil {
+		s.Failures++
+	}
+	if testCase.Skipped != nil {
+		s.Skipped++
+	}
+}
+
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
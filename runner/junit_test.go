@@ -0,0 +1,50 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJUnitReport(t *testing.T) {
+	report := NewJUnitReport()
+	global := NewGlobalContext().
+		WithStdout(&bytes.Buffer{}).
+		WithErrout(&bytes.Buffer{}).
+		WithObserver(report).
+		WithCapturedStderr(true)
+
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	pkg.Actions["install"] = NewActionContext(global, pkg, "install", runfile.Action{
+		Skip: runfile.Skip{Shell: "true", Message: "already installed"},
+	})
+	pkg.Actions["test"] = NewActionContext(global, pkg, "test", runfile.Action{
+		Dependencies: []string{"install"},
+		Commands: []runfile.Command{
+			{Shell: "echo ok"},
+			{Shell: "echo broken >&2; exit 2"},
+		},
+	})
+
+	assert.Error(t, pkg.Run("test", nil))
+
+	assert.Len(t, report.suites, 2)
+
+	test := report.suites[0]
+	assert.Equal(t, "test", test.Name)
+	assert.Equal(t, 2, test.Tests)
+	assert.Equal(t, 1, test.Failures)
+	assert.Nil(t, test.Cases[0].Failure)
+	assert.Equal(t, "exit code 2", test.Cases[1].Failure.Message)
+	assert.Equal(t, "broken\n", test.Cases[1].SystemErr)
+
+	install := report.suites[1]
+	assert.Equal(t, 1, install.Skipped)
+	assert.Equal(t, "already installed", install.Cases[0].Skipped.Message)
+
+	var out bytes.Buffer
+	assert.NoError(t, report.Write(&out))
+	assert.Contains(t, out.String(), `<testsuites tests="3" failures="1" skipped="1">`)
+}
@@ -0,0 +1,150 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+type OutputMode string
+
+const (
+	// OutputRaw passes the output of commands through untouched.
+	OutputRaw OutputMode = "raw"
+	// OutputInterleaved prefixes every line with the name of its action.
+	OutputInterleaved OutputMode = "interleaved"
+	// OutputGrouped holds the output of a command until it completes.
+	OutputGrouped OutputMode = "grouped"
+)
+
+func ParseOutputMode(mode string) (OutputMode, error) {
+	switch OutputMode(mode) {
+	case OutputRaw, OutputInterleaved, OutputGrouped:
+		return OutputMode(mode), nil
+	}
+	return "", errors.Errorf("unknown output mode '%s', expected raw, interleaved or grouped", mode)
+}
+
+var colors = []int{31, 32, 33, 34, 35, 36}
+
+// commandOutput returns the writers for the stdout and stderr of a command run
+// by the named action. flush must be called once the command completes.
+func (c *GlobalContext) commandOutput(name string) (io.Writer, io.Writer, func()) {
+	stdout, stderr, flush := c.modeOutput(name)
+	if c.secrets.empty() {
+		return stdout, stderr, flush
+	}
+	maskedOut := &maskWriter{secrets: &c.secrets, dst: stdout}
+	maskedErr := &maskWriter{secrets: &c.secrets, dst: stderr}
+	return maskedOut, maskedErr, func() {
+		maskedOut.Flush()
+		maskedErr.Flush()
+		flush()
+	}
+}
+
+func (c *GlobalContext) modeOutput(name string) (io.Writer, io.Writer, func()) {
+	switch c.output {
+	case OutputInterleaved:
+		prefix := c.prefix(name)
+		stdout := &prefixWriter{mu: &c.writeMu, dst: c.out, prefix: prefix}
+		stderr := &prefixWriter{mu: &c.writeMu, dst: c.err, prefix: prefix}
+		return stdout, stderr, func() {
+			stdout.Flush()
+			stderr.Flush()
+		}
+	case OutputGrouped:
+		group := &groupWriter{mu: &c.writeMu}
+		return group.writer(c.out), group.writer(c.err), group.Flush
+	}
+	return c.out, c.err, func() {}
+}
+
+func (c *GlobalContext) prefix(name string) []byte {
+	if !c.color {
+		return []byte("[" + name + "] ")
+	}
+	hash := fnv.New32a()
+	hash.Write([]byte(name))
+	color := colors[hash.Sum32()%uint32(len(colors))]
+	return []byte(fmt.Sprintf("\x1b[%dm[%s]\x1b[0m ", color, name))
+}
+
+// prefixWriter writes complete lines to dst, each starting with prefix.
+type prefixWriter struct {
+	mu     *sync.Mutex
+	dst    io.Writer
+	prefix []byte
+	line   []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	for {
+		i := bytes.IndexByte(w.line, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		if err := w.writeLine(w.line[:i+1]); err != nil {
+			return 0, err
+		}
+		w.line = w.line[i+1:]
+	}
+}
+
+// Flush writes a trailing partial line.
+func (w *prefixWriter) Flush() {
+	if len(w.line) > 0 {
+		w.writeLine(append(w.line, '\n'))
+		w.line = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	_, err := w.dst.Write(append(append([]byte{}, w.prefix...), line...))
+	return err
+}
+
+// groupWriter holds the output written to it, in order, until it is flushed.
+type groupWriter struct {
+	mu     *sync.Mutex
+	chunks []groupChunk
+	local  sync.Mutex
+}
+
+type groupChunk struct {
+	dst  io.Writer
+	data []byte
+}
+
+func (g *groupWriter) writer(dst io.Writer) io.Writer {
+	return writerFunc(func(p []byte) (int, error) {
+		g.local.Lock()
+		defer g.local.Unlock()
+		g.chunks = append(g.chunks, groupChunk{dst: dst, data: append([]byte{}, p...)})
+		return len(p), nil
+	})
+}
+
+func (g *groupWriter) Flush() {
+	g.local.Lock()
+	defer g.local.Unlock()
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for _, chunk := range g.chunks {
+		chunk.dst.Write(chunk.data)
+	}
+	g.chunks = nil
+}
+
+type writerFunc func([]byte) (int, error)
+
+func (f writerFunc) Write(p []byte) (int, error) {
+	return f(p)
+}
@@ -0,0 +1,41 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobalContext_CommandOutput(t *testing.T) {
+	t.Run("interleaved", func(t *testing.T) {
+		var out, err bytes.Buffer
+		global := NewGlobalContext().WithStdout(&out).WithErrout(&err).WithOutputMode(OutputInterleaved)
+
+		stdout, stderr, flush := global.commandOutput("go.build")
+		fmt.Fprint(stdout, "compiling\nlinking")
+		fmt.Fprint(stderr, "warning\n")
+		fmt.Fprint(stdout, " done\npartial")
+		flush()
+
+		assert.Equal(t, "[go.build] compiling\n[go.build] linking done\n[go.build] partial\n", out.String())
+		assert.Equal(t, "[go.build] warning\n", err.String())
+	})
+
+	t.Run("grouped", func(t *testing.T) {
+		var out bytes.Buffer
+		global := NewGlobalContext().WithStdout(&out).WithErrout(&out).WithOutputMode(OutputGrouped)
+
+		stdout1, stderr1, flush1 := global.commandOutput("lint")
+		stdout2, _, flush2 := global.commandOutput("test")
+		fmt.Fprint(stdout1, "lint 1\n")
+		fmt.Fprint(stdout2, "test 1\n")
+		fmt.Fprint(stderr1, "lint 2\n")
+		assert.Empty(t, out.String())
+
+		flush2()
+		flush1()
+		assert.Equal(t, "test 1\nlint 1\nlint 2\n", out.String())
+	})
+}
@@ -1,12 +1,16 @@
 package runner
 
 import (
+	"strings"
+
 	"github.com/campbel/run/runfile"
 	"github.com/pkg/errors"
 )
 
 type PackageContext struct {
 	Global  *GlobalContext
+	Name    string
+	URI     string
 	Dir     string
 	env     map[string]string
 	Actions map[string]*ActionContext
@@ -23,13 +27,50 @@ func NewPackageContext(global *GlobalContext, rf *runfile.Runfile) *PackageConte
 	}
 }
 
+// WithName sets the name the package is imported as.
+func (ctx *PackageContext) WithName(name string) *PackageContext {
+	ctx.Name = name
+	return ctx
+}
+
+// WithURI sets the URI the package was fetched from.
+func (ctx *PackageContext) WithURI(uri string) *PackageContext {
+	ctx.URI = uri
+	return ctx
+}
+
+// Run runs the named action, the secrets of the run are masked in the error.
 func (ctx *PackageContext) Run(actionName string, passedArgs map[string]string) error {
+	return ctx.Global.secrets.maskError(ctx.run(0, false, false, actionName, passedArgs))
+}
+
+func (ctx *PackageContext) run(parent int, dep, silent bool, actionName string, passedArgs map[string]string) error {
 	if action, exists := ctx.Actions[actionName]; exists {
-		return action.Run(passedArgs)
+		return action.run(parent, dep, silent, passedArgs)
 	}
 	return errors.Errorf("no action with the name '%s'", actionName)
 }
 
+// runReference runs an action referenced from this package, either by its
+// name or qualified by the name of an import, e.g. go.install_pkg.
+func (ctx *PackageContext) runReference(parent int, dep, silent bool, ref string, passedArgs map[string]string) error {
+	if strings.Contains(ref, ".") {
+		parts := strings.SplitN(ref, ".", 2)
+		pkg, action := parts[0], parts[1]
+		if packageCtx, exists := ctx.Imports[pkg]; exists {
+			if err := packageCtx.run(parent, dep, silent, action, passedArgs); err != nil {
+				return errors.Wrap(err, "error running action")
+			}
+			return nil
+		}
+		return errors.Errorf("no package with the name '%s'", pkg)
+	}
+	if action, exists := ctx.Actions[ref]; exists {
+		return action.run(parent, dep, silent, passedArgs)
+	}
+	return errors.Errorf("no action with the name '%s'", ref)
+}
+
 func (ctx *PackageContext) Env() map[string]string {
 	return ctx.env
 }
@@ -0,0 +1,86 @@
+package runner
+
+import (
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/campbel/run/runfile"
+	"github.com/pkg/errors"
+)
+
+type PreconditionContext struct {
+	actionContext *ActionContext
+	Shell         string
+	Expr          string
+	Message       string
+}
+
+func NewPreconditionContexts(actionContext *ActionContext, preconditions []runfile.Precondition) []*PreconditionContext {
+	var contexts []*PreconditionContext
+	for _, precondition := range preconditions {
+		contexts = append(contexts, NewPreconditionContext(actionContext, precondition))
+	}
+	return contexts
+}
+
+func NewPreconditionContext(actionContext *ActionContext, precondition runfile.Precondition) *PreconditionContext {
+	return &PreconditionContext{
+		actionContext: actionContext,
+		Shell:         precondition.Shell,
+		Expr:          precondition.Expr,
+		Message:       precondition.Message,
+	}
+}
+
+// Check reports whether the precondition holds. A shell check holds when the
+// command exits successfully, an expression when it renders to "true".
+func (ctx *PreconditionContext) Check(vars any) (bool, error) {
+	if ctx.Shell != "" {
+		subbedCommand, err := varSub(vars, ctx.Shell)
+		if err != nil {
+			return false, err
+		}
+		command := exec.Command("sh", "-c", subbedCommand)
+		command.Env = commandEnv(ctx.actionContext.Env())
+		return command.Run() == nil, nil
+	}
+	if ctx.Expr != "" {
+		result, err := varSub(vars, ctx.Expr)
+		if err != nil {
+			return false, err
+		}
+		ok, _ := strconv.ParseBool(strings.TrimSpace(result))
+		return ok, nil
+	}
+	return true, nil
+}
+
+// Description is the message reported when the precondition fails.
+func (ctx *PreconditionContext) Description() string {
+	if ctx.Message != "" {
+		return ctx.Message
+	}
+	if ctx.Shell != "" {
+		return ctx.Shell
+	}
+	return ctx.Expr
+}
+
+// checkPreconditions runs every precondition and reports all failures together.
+func checkPreconditions(name string, preconditions []*PreconditionContext, vars any) error {
+	var failed []string
+	for _, precondition := range preconditions {
+		ok, err := precondition.Check(vars)
+		if err != nil {
+			return errors.Wrap(err, "error checking precondition")
+		}
+		if !ok {
+			failed = append(failed, precondition.Description())
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return errors.Errorf("preconditions failed for '%s':\n  - %s", name, strings.Join(failed, "\n  - "))
+}
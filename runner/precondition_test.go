@@ -0,0 +1,49 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionContext_Preconditions(t *testing.T) {
+	var out bytes.Buffer
+	global := NewGlobalContext().WithStdout(&out)
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	pkg.Actions["install"] = NewActionContext(global, pkg, "install", runfile.Action{
+		Commands: []runfile.Command{{Shell: "echo installing"}},
+	})
+	deploy := NewActionContext(global, pkg, "deploy", runfile.Action{
+		Dependencies: []string{"install"},
+		Preconditions: []runfile.Precondition{
+			{Shell: "true", Message: "always holds"},
+			{Shell: "false", Message: "docker must be running"},
+			{Expr: `{{ eq .ARGS.ENV "staging" }}`, Message: "ENV must be staging"},
+			{Expr: `{{ ne .ARGS.ENV "" }}`},
+		},
+		Commands: []runfile.Command{{Shell: "echo deploying"}},
+	})
+
+	err := deploy.Run(map[string]string{"ENV": "production"})
+	assert.EqualError(t, err, "preconditions failed for 'deploy':\n  - docker must be running\n  - ENV must be staging")
+	assert.Empty(t, out.String())
+}
+
+func TestActionContext_PreconditionsInput(t *testing.T) {
+	global := NewGlobalContext().WithProfile("ci", runfile.Profile{Env: map[string]string{"REGION": "eu"}})
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	action := NewActionContext(global, pkg, "deploy", runfile.Action{
+		Args: map[string]runfile.Arg{"ENV": {Default: "staging"}},
+		Env:  map[string]runfile.EnvVar{"CLUSTER": {Value: "main"}},
+		Vars: map[string]runfile.Var{"VERSION": {Value: "1.2.3"}},
+		Preconditions: []runfile.Precondition{
+			{Shell: `test "$REGION-$CLUSTER" = eu-main`, Message: "sees env"},
+			{Expr: `{{ and (eq .ARGS.ENV "staging") (eq .PROFILE "ci") }}`, Message: "sees args and profile"},
+			{Expr: `{{ not .VARS }}`, Message: "does not see vars"},
+		},
+	})
+
+	assert.NoError(t, action.Run(nil))
+}
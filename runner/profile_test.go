@@ -0,0 +1,66 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGlobalContext_WithProfile(t *testing.T) {
+	rf, err := runfile.Unmarshal([]byte(`
+profiles:
+  staging:
+    env:
+      REGION: eu-west-1
+      TOKEN: staging-token
+    vars:
+      CLUSTER: staging-cluster
+      PASSWORD: staging-password
+    args:
+      ENV: staging
+      REPLICAS: "2"
+`))
+	assert.NoError(t, err)
+
+	t.Run("unknown profile", func(t *testing.T) {
+		_, err := rf.Profile("production")
+		assert.EqualError(t, err, "no profile with the name 'production'")
+	})
+
+	profile, err := rf.Profile("staging")
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	global := NewGlobalContext().WithStdout(&out).WithProfile("staging", profile)
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	deploy := NewActionContext(global, pkg, "deploy", runfile.Action{
+		Args: map[string]runfile.Arg{
+			"ENV":      {Default: "dev"},
+			"REPLICAS": {Default: "1"},
+			"VERSION":  {Default: "latest"},
+		},
+		Vars: map[string]runfile.Var{
+			"CLUSTER":  {Value: "dev-cluster"},
+			"PASSWORD": {Value: "dev-password", Secret: true},
+			"NAME":     {Shell: "echo {{ .ARGS.ENV }}-app"},
+		},
+		Env: map[string]runfile.EnvVar{
+			"TOKEN":  {Value: "dev-token", Secret: true},
+			"BUCKET": {Shell: "echo assets-$REGION"},
+		},
+		Commands: []runfile.Command{
+			{Shell: "echo {{ .PROFILE }} {{ .ARGS.ENV }} {{ .ARGS.REPLICAS }} {{ .ARGS.VERSION }}"},
+			{Shell: "echo {{ .VARS.CLUSTER }} {{ .VARS.NAME }} {{ .VARS.PASSWORD }}"},
+			{Shell: "echo $REGION $BUCKET $TOKEN"},
+		},
+	})
+
+	assert.NoError(t, deploy.Run(map[string]string{"REPLICAS": "3"}))
+	assert.Equal(t, ""+
+		"staging staging 3 latest\n"+
+		"staging-cluster staging-app ***\n"+
+		"eu-west-1 assets-eu-west-1 ***\n",
+		out.String())
+}
@@ -0,0 +1,133 @@
+package runner
+
+import (
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"golang.org/x/term"
+)
+
+// prompt asks the user for a value. When choices are given the answer must be
+// one of them, either by value or by its number in the list.
+func (c *GlobalContext) prompt(question string, choices []string) (string, error) {
+	if !c.canPrompt() {
+		return "", errors.Errorf("a value is required for %q but the run is not interactive", question)
+	}
+
+	for i, choice := range choices {
+		c.fprintf(c.err, "  %d) %s\n", i+1, choice)
+	}
+	for {
+		c.fprintf(c.err, "%s ", question)
+		answer, err := readLine(c.in)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read answer for %q", question)
+		}
+		if len(choices) == 0 {
+			return answer, nil
+		}
+		if choice, ok := matchChoice(answer, choices); ok {
+			return choice, nil
+		}
+		c.fprintf(c.err, "invalid choice %q, expected one of: %s\n", answer, strings.Join(choices, ", "))
+	}
+}
+
+// confirm asks the user a yes/no question, defaulting to no.
+func (c *GlobalContext) confirm(question string) (bool, error) {
+	if c.assumeYes {
+		return true, nil
+	}
+	if !c.interactive {
+		return false, errors.Errorf("confirmation required for %q, use --yes to confirm when not interactive", question)
+	}
+
+	c.fprintf(c.err, "%s [y/N] ", question)
+	answer, err := readLine(c.in)
+	if err != nil {
+		return false, errors.Wrapf(err, "failed to read answer for %q", question)
+	}
+	switch strings.ToLower(answer) {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// promptPassword asks the user for a value without echoing it on a terminal.
+func (c *GlobalContext) promptPassword(question string) (string, error) {
+	if !c.canPrompt() {
+		return "", errors.Errorf("a value is required for %q but the run is not interactive", question)
+	}
+
+	c.fprintf(c.err, "%s ", question)
+	file, ok := c.in.(*os.File)
+	if !ok || !term.IsTerminal(int(file.Fd())) {
+		answer, err := readLine(c.in)
+		return answer, errors.Wrapf(err, "failed to read answer for %q", question)
+	}
+	password, err := term.ReadPassword(int(file.Fd()))
+	// The newline typed by the user is not echoed either
+	c.fprintf(c.err, "\n")
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read answer for %q", question)
+	}
+	return strings.TrimSpace(string(password)), nil
+}
+
+func (c *GlobalContext) canPrompt() bool {
+	return c.interactive && !c.assumeYes
+}
+
+func matchChoice(answer string, choices []string) (string, bool) {
+	for _, choice := range choices {
+		if answer == choice {
+			return choice, true
+		}
+	}
+	if i, err := strconv.Atoi(answer); err == nil && i > 0 && i <= len(choices) {
+		return choices[i-1], true
+	}
+	return "", false
+}
+
+// readLine reads a single line one byte at a time, so nothing past the line is
+// consumed from an input that is later handed to child processes.
+func readLine(in io.Reader) (string, error) {
+	var line []byte
+	buf := make([]byte, 1)
+	for {
+		n, err := in.Read(buf)
+		if n > 0 {
+			if buf[0] == '\n' {
+				break
+			}
+			line = append(line, buf[0])
+		}
+		if err == io.EOF {
+			if len(line) == 0 {
+				return "", err
+			}
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSpace(string(line)), nil
+}
+
+func isTerminal(in io.Reader) bool {
+	file, ok := in.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,75 @@
+package runner
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestActionContext_Prompt(t *testing.T) {
+	action := runfile.Action{
+		Confirm: "Deploy to {{ .ARGS.ENV }}?",
+		Args: map[string]runfile.Arg{
+			"ENV": {Prompt: "Which environment?", Choices: []string{"staging", "production"}},
+		},
+		Commands: []runfile.Command{
+			{Shell: "echo deploying {{ .ARGS.ENV }}"},
+		},
+	}
+
+	newAction := func(global *GlobalContext) *ActionContext {
+		pkg := NewPackageContext(global, runfile.NewRunfile())
+		return NewActionContext(global, pkg, "deploy", action)
+	}
+
+	t.Run("prompts for missing args and confirms", func(t *testing.T) {
+		var out bytes.Buffer
+		global := NewGlobalContext().
+			WithStdout(&out).
+			WithErrout(&bytes.Buffer{}).
+			WithStdin(strings.NewReader("qa\n2\ny\n")).
+			WithInteractive(true)
+
+		assert.NoError(t, newAction(global).Run(nil))
+		assert.Equal(t, "deploying production\n", out.String())
+	})
+
+	t.Run("declined confirmation", func(t *testing.T) {
+		var out bytes.Buffer
+		global := NewGlobalContext().
+			WithStdout(&out).
+			WithErrout(&bytes.Buffer{}).
+			WithStdin(strings.NewReader("n\n")).
+			WithInteractive(true)
+
+		assert.Error(t, newAction(global).Run(map[string]string{"ENV": "staging"}))
+		assert.Empty(t, out.String())
+	})
+
+	t.Run("fails fast when not interactive", func(t *testing.T) {
+		global := NewGlobalContext().WithStdin(strings.NewReader("staging\n"))
+
+		err := newAction(global).Run(nil)
+		assert.ErrorContains(t, err, "not interactive")
+
+		err = newAction(global).Run(map[string]string{"ENV": "staging"})
+		assert.ErrorContains(t, err, "--yes")
+	})
+
+	t.Run("yes confirms but never prompts", func(t *testing.T) {
+		var out bytes.Buffer
+		global := NewGlobalContext().
+			WithStdout(&out).
+			WithStdin(strings.NewReader("")).
+			WithInteractive(true).
+			WithAssumeYes(true)
+
+		assert.NoError(t, newAction(global).Run(map[string]string{"ENV": "staging"}))
+		assert.Equal(t, "deploying staging\n", out.String())
+
+		assert.ErrorContains(t, newAction(global).Run(nil), "not interactive")
+	})
+}
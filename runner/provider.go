@@ -0,0 +1,239 @@
+package runner
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/scrypt"
+	"gopkg.in/yaml.v3"
+)
+
+// SecretProvider fetches secrets from a backend. The ref is the secret
+// reference without its scheme, e.g. DB_PASS for env://DB_PASS.
+type SecretProvider interface {
+	Secret(ref string) (string, error)
+}
+
+// EnvSecretProvider reads secrets from the environment of the runner.
+type EnvSecretProvider struct{}
+
+func (EnvSecretProvider) Secret(ref string) (string, error) {
+	value, ok := os.LookupEnv(ref)
+	if !ok {
+		return "", errors.Errorf("environment variable %s is not set", ref)
+	}
+	return value, nil
+}
+
+// FileSecretProvider reads secrets from a YAML file of names to values,
+// referenced as path#name. The file may be encrypted with EncryptSecrets.
+type FileSecretProvider struct {
+	passphrase func() (string, error)
+
+	mu    sync.Mutex
+	files map[string]map[string]string
+}
+
+func NewFileSecretProvider(passphrase func() (string, error)) *FileSecretProvider {
+	return &FileSecretProvider{
+		passphrase: passphrase,
+		files:      make(map[string]map[string]string),
+	}
+}
+
+func (p *FileSecretProvider) Secret(ref string) (string, error) {
+	path, name, ok := strings.Cut(ref, "#")
+	if !ok || name == "" {
+		return "", errors.Errorf("secret reference '%s' is missing a #name", ref)
+	}
+	values, err := p.load(expandHome(path))
+	if err != nil {
+		return "", err
+	}
+	value, ok := values[name]
+	if !ok {
+		return "", errors.Errorf("no secret with the name '%s' in %s", name, path)
+	}
+	return value, nil
+}
+
+func (p *FileSecretProvider) load(path string) (map[string]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if values, ok := p.files[path]; ok {
+		return values, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read secrets file %s", path)
+	}
+	if isEncrypted(data) {
+		passphrase, err := p.passphrase()
+		if err != nil {
+			return nil, err
+		}
+		if data, err = DecryptSecrets(passphrase, data); err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt secrets file %s", path)
+		}
+	}
+
+	values := make(map[string]string)
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, errors.Wrapf(err, "failed to unmarshal secrets file %s", path)
+	}
+	p.files[path] = values
+	return values, nil
+}
+
+const encryptedHeader = "run-secrets:v1\n"
+
+func isEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(encryptedHeader))
+}
+
+// EncryptSecrets encrypts a secrets file with AES-GCM, using a key derived
+// from the passphrase with scrypt.
+func EncryptSecrets(passphrase string, plaintext []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := secretsCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	sealed := append(append(salt, nonce...), gcm.Seal(nil, nonce, plaintext, nil)...)
+	return []byte(encryptedHeader + base64.StdEncoding.EncodeToString(sealed) + "\n"), nil
+}
+
+func DecryptSecrets(passphrase string, data []byte) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(string(data), encryptedHeader)))
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < 16 {
+		return nil, errors.New("encrypted secrets are truncated")
+	}
+	gcm, err := secretsCipher(passphrase, sealed[:16])
+	if err != nil {
+		return nil, err
+	}
+	sealed = sealed[16:]
+	if len(sealed) < gcm.NonceSize() {
+		return nil, errors.New("encrypted secrets are truncated")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, errors.New("wrong passphrase or corrupted secrets")
+	}
+	return plaintext, nil
+}
+
+func secretsCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// secretRef matches the secrets referenced by a template, e.g. .SECRETS.db_password
+// or index .SECRETS "db-password"
+var secretRef = regexp.MustCompile(`\.(?:SECRETS|secrets)\.([A-Za-z_][A-Za-z0-9_]*)|index\s+\.(?:SECRETS|secrets)\s+(?:"([^"]+)"|` + "`([^`]+)`)")
+
+// secretRefs returns the names of the secrets referenced by the templates.
+func secretRefs(templates ...string) []string {
+	seen := make(map[string]bool)
+	var names []string
+	for _, template := range templates {
+		for _, match := range secretRef.FindAllStringSubmatch(template, -1) {
+			name := match[1] + match[2] + match[3]
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// WithSecretProvider registers a provider for secret references with the scheme.
+func (c *GlobalContext) WithSecretProvider(scheme string, provider SecretProvider) *GlobalContext {
+	c.providers[scheme] = provider
+	return c
+}
+
+// WithSecrets sets the secrets available to actions, by name, as references
+// such as env://DB_PASS. They are only fetched by actions that use them.
+func (c *GlobalContext) WithSecrets(refs map[string]string) *GlobalContext {
+	c.secretRefs = refs
+	return c
+}
+
+// secret fetches a secret by name once, it is masked from then on.
+func (c *GlobalContext) secret(name string) (string, error) {
+	c.secretMu.Lock()
+	defer c.secretMu.Unlock()
+	if value, ok := c.secretValues[name]; ok {
+		return value, nil
+	}
+
+	ref, ok := c.secretRefs[name]
+	if !ok {
+		return "", errors.Errorf("no secret with the name '%s'", name)
+	}
+	scheme, path, ok := strings.Cut(ref, "://")
+	if !ok {
+		return "", errors.Errorf("secret reference '%s' has no scheme", ref)
+	}
+	provider, ok := c.providers[scheme]
+	if !ok {
+		return "", errors.Errorf("no secret provider for '%s'", scheme)
+	}
+	value, err := provider.Secret(path)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to fetch secret '%s'", name)
+	}
+	c.secrets.add(value)
+	c.secretValues[name] = value
+	return value, nil
+}
+
+// SecretsPassphrase returns the passphrase of encrypted secrets files, from
+// RUN_SECRETS_PASSPHRASE or asked for without echo. With confirm it is asked
+// for twice, for passphrases that encrypt.
+func (c *GlobalContext) SecretsPassphrase(confirm bool) (string, error) {
+	if passphrase, ok := os.LookupEnv("RUN_SECRETS_PASSPHRASE"); ok {
+		return passphrase, nil
+	}
+	passphrase, err := c.promptPassword("Secrets passphrase:")
+	if err != nil || !confirm {
+		return passphrase, err
+	}
+	repeated, err := c.promptPassword("Repeat passphrase:")
+	if err != nil {
+		return "", err
+	}
+	if repeated != passphrase {
+		return "", errors.New("passphrases do not match")
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,104 @@
+package runner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileSecretProvider(t *testing.T) {
+	dir := t.TempDir()
+	encrypted, err := EncryptSecrets("passphrase", []byte("db_password: hunter22\n"))
+	assert.NoError(t, err)
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "secrets.yaml"), encrypted, 0600))
+
+	t.Run("decrypts with the passphrase", func(t *testing.T) {
+		provider := NewFileSecretProvider(func() (string, error) { return "passphrase", nil })
+		value, err := provider.Secret(filepath.Join(dir, "secrets.yaml") + "#db_password")
+		assert.NoError(t, err)
+		assert.Equal(t, "hunter22", value)
+
+		_, err = provider.Secret(filepath.Join(dir, "secrets.yaml") + "#missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("wrong passphrase", func(t *testing.T) {
+		provider := NewFileSecretProvider(func() (string, error) { return "wrong", nil })
+		_, err := provider.Secret(filepath.Join(dir, "secrets.yaml") + "#db_password")
+		assert.ErrorContains(t, err, "wrong passphrase")
+	})
+}
+
+type countingProvider struct {
+	fetched []string
+}
+
+func (p *countingProvider) Secret(ref string) (string, error) {
+	p.fetched = append(p.fetched, ref)
+	return "value-of-" + ref, nil
+}
+
+func TestGlobalContext_Secrets(t *testing.T) {
+	provider := &countingProvider{}
+	var out bytes.Buffer
+	global := NewGlobalContext().
+		WithStdout(&out).
+		WithSecretProvider("test", provider).
+		WithSecrets(map[string]string{
+			"db":    "test://db",
+			"token": "test://token",
+		})
+
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	pkg.Actions["echo"] = NewActionContext(global, pkg, "echo", runfile.Action{
+		Commands: []runfile.Command{{Shell: "echo plain"}},
+	})
+	pkg.Actions["deploy"] = NewActionContext(global, pkg, "deploy", runfile.Action{
+		Env:      map[string]runfile.EnvVar{"DB": {From: "db"}},
+		Commands: []runfile.Command{{Shell: `echo "$DB"; echo "{{ .SECRETS.db }}"`}},
+	})
+
+	assert.NoError(t, pkg.Run("echo", nil))
+	assert.Empty(t, provider.fetched)
+
+	assert.NoError(t, pkg.Run("deploy", nil))
+	assert.NoError(t, pkg.Run("deploy", nil))
+	assert.Equal(t, []string{"db"}, provider.fetched)
+	assert.Equal(t, "plain\n***\n***\n***\n***\n", out.String())
+}
+
+func TestSecretRefs(t *testing.T) {
+	assert.Equal(t, []string{"db", "api-key", "db_password"}, secretRefs(
+		`{{ .SECRETS.db }} {{ index .SECRETS "api-key" }}`,
+		"{{ index .secrets `db_password` }} {{ .SECRETS.db }}",
+	))
+}
+
+func TestGlobalContext_SecretsPassphrase(t *testing.T) {
+	newGlobal := func(input string) *GlobalContext {
+		return NewGlobalContext().
+			WithErrout(&bytes.Buffer{}).
+			WithStdin(strings.NewReader(input)).
+			WithInteractive(true)
+	}
+
+	passphrase, err := newGlobal("hunter22\nhunter22\n").SecretsPassphrase(true)
+	assert.NoError(t, err)
+	assert.Equal(t, "hunter22", passphrase)
+
+	_, err = newGlobal("hunter22\nhunter23\n").SecretsPassphrase(true)
+	assert.EqualError(t, err, "passphrases do not match")
+
+	_, err = NewGlobalContext().WithStdin(strings.NewReader("")).SecretsPassphrase(false)
+	assert.ErrorContains(t, err, "not interactive")
+
+	t.Setenv("RUN_SECRETS_PASSPHRASE", "from-env")
+	passphrase, err = newGlobal("").SecretsPassphrase(true)
+	assert.NoError(t, err)
+	assert.Equal(t, "from-env", passphrase)
+}
@@ -0,0 +1,170 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+const mask = "***"
+
+// minSecretLength keeps very short values from masking unrelated output.
+const minSecretLength = 3
+
+// secretSet holds every secret value resolved during a run, so it can be
+// masked in all output the runner controls.
+type secretSet struct {
+	mu       sync.RWMutex
+	values   map[string]bool
+	replacer *strings.Replacer
+}
+
+func (s *secretSet) add(value string) {
+	if len(value) < minSecretLength {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values == nil {
+		s.values = make(map[string]bool)
+	}
+	if s.values[value] {
+		return
+	}
+	s.values[value] = true
+
+	// Longer values are replaced first so a secret containing another is
+	// masked as a whole
+	values := make([]string, 0, len(s.values))
+	for value := range s.values {
+		values = append(values, value)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+	var pairs []string
+	for _, value := range values {
+		pairs = append(pairs, value, mask)
+	}
+	s.replacer = strings.NewReplacer(pairs...)
+}
+
+func (s *secretSet) empty() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.replacer == nil
+}
+
+func (s *secretSet) mask(text string) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.replacer == nil {
+		return text
+	}
+	return s.replacer.Replace(text)
+}
+
+// maskEvent masks the secrets in every text of an event before observers see it.
+func (s *secretSet) maskEvent(event Event) Event {
+	if s.empty() {
+		return event
+	}
+	event.Command = s.mask(event.Command)
+	event.Value = s.mask(event.Value)
+	event.Message = s.mask(event.Message)
+	event.Stderr = s.mask(event.Stderr)
+	event.Err = s.maskError(event.Err)
+	return event
+}
+
+// maskError masks the secrets in the message of an error, keeping the error
+// it wraps.
+func (s *secretSet) maskError(err error) error {
+	if err == nil || s.empty() {
+		return err
+	}
+	if message := s.mask(err.Error()); message != err.Error() {
+		return &maskedError{err: err, message: message}
+	}
+	return err
+}
+
+type maskedError struct {
+	err     error
+	message string
+}
+
+func (e *maskedError) Error() string {
+	return e.message
+}
+
+func (e *maskedError) Unwrap() error {
+	return e.err
+}
+
+// maskWriter masks secrets in complete lines, so a secret split across writes
+// is still masked. The last partial line is written on Flush.
+type maskWriter struct {
+	secrets *secretSet
+	dst     io.Writer
+	line    []byte
+}
+
+func (w *maskWriter) Write(p []byte) (int, error) {
+	w.line = append(w.line, p...)
+	i := bytes.LastIndexByte(w.line, '\n')
+	if i < 0 {
+		return len(p), nil
+	}
+	if _, err := io.WriteString(w.dst, w.secrets.mask(string(w.line[:i+1]))); err != nil {
+		return 0, err
+	}
+	w.line = w.line[i+1:]
+	return len(p), nil
+}
+
+func (w *maskWriter) Flush() {
+	if len(w.line) > 0 {
+		io.WriteString(w.dst, w.secrets.mask(string(w.line)))
+		w.line = nil
+	}
+}
+
+// fprintf writes the formatted text to w with the secrets masked.
+func (c *GlobalContext) fprintf(w io.Writer, format string, args ...any) {
+	io.WriteString(w, c.secrets.mask(fmt.Sprintf(format, args...)))
+}
+
+// readSource reads a value from a file or an environment variable of the
+// runner, reporting whether either source was set.
+func readSource(file, envName string) (string, bool, error) {
+	if file != "" {
+		data, err := os.ReadFile(expandHome(file))
+		if err != nil {
+			return "", true, errors.Wrapf(err, "failed to read %s", file)
+		}
+		return strings.TrimSpace(string(data)), true, nil
+	}
+	if envName != "" {
+		value, ok := os.LookupEnv(envName)
+		if !ok {
+			return "", true, errors.Errorf("environment variable %s is not set", envName)
+		}
+		return value, true, nil
+	}
+	return "", false, nil
+}
+
+func expandHome(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, path[1:])
+		}
+	}
+	return path
+}
@@ -0,0 +1,92 @@
+package runner
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecrets(t *testing.T) {
+	t.Setenv("RUN_TEST_TOKEN", "s3cr3t-token")
+
+	var out, events bytes.Buffer
+	global := NewGlobalContext().
+		WithStdout(&out).
+		WithErrout(&out).
+		WithObserver(NewJSONEvents(&events)).
+		WithVerbose(true)
+
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	pkg.Actions["deploy"] = NewActionContext(global, pkg, "deploy", runfile.Action{
+		Vars: map[string]runfile.Var{
+			"TOKEN": {Env: "RUN_TEST_TOKEN", Secret: true},
+		},
+		Env: map[string]runfile.EnvVar{
+			"PASSWORD": {Shell: "echo hunter2", Secret: true},
+		},
+		Commands: []runfile.Command{
+			{Shell: `echo "token={{ .VARS.TOKEN }}"; printf "pass=$PASSWORD"`},
+		},
+	})
+
+	assert.NoError(t, pkg.Run("deploy", nil))
+	assert.NotContains(t, out.String(), "s3cr3t-token")
+	assert.NotContains(t, out.String(), "hunter2")
+	assert.Contains(t, out.String(), "token=***\npass=***")
+	assert.Contains(t, out.String(), `+ [deploy] echo "token=***"`)
+	assert.NotContains(t, events.String(), "s3cr3t-token")
+}
+
+func TestSecrets_SkipPromptAndErrors(t *testing.T) {
+	t.Setenv("RUN_TEST_TOKEN", "s3cr3t-token")
+
+	var out, errout bytes.Buffer
+	global := NewGlobalContext().
+		WithStdout(&out).
+		WithErrout(&errout).
+		WithStdin(strings.NewReader("n\n")).
+		WithInteractive(true).
+		WithSkipOutput(true)
+
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	pkg.Actions["login"] = NewActionContext(global, pkg, "login", runfile.Action{
+		Vars: map[string]runfile.Var{
+			"TOKEN": {Env: "RUN_TEST_TOKEN", Secret: true},
+		},
+		Skip: runfile.Skip{Shell: `echo "checking {{ .VARS.TOKEN }}"; echo "{{ .VARS.TOKEN }}" >&2; false`},
+	})
+	pkg.Actions["deploy"] = NewActionContext(global, pkg, "deploy", runfile.Action{
+		Dependencies: []string{"login"},
+		Confirm:      "Deploy with {{ .SECRETS.token }}?",
+	})
+	global.WithSecrets(map[string]string{"token": "env://RUN_TEST_TOKEN"})
+
+	assert.NoError(t, pkg.Run("login", nil))
+	assert.Equal(t, "checking ***\n", out.String())
+	assert.Equal(t, "***\n", errout.String())
+
+	err := pkg.Run("deploy", nil)
+	assert.EqualError(t, err, "aborted 'deploy': Deploy with ***?")
+	assert.NotContains(t, errout.String(), "s3cr3t-token")
+	assert.Contains(t, errout.String(), "Deploy with ***? [y/N]")
+}
+
+func TestMaskWriter(t *testing.T) {
+	var secrets secretSet
+	secrets.add("abcdef")
+	secrets.add("abc")
+	secrets.add("x")
+
+	var out bytes.Buffer
+	w := &maskWriter{secrets: &secrets, dst: &out}
+	fmt.Fprint(w, "one abc")
+	fmt.Fprint(w, "def\ntwo ab")
+	assert.Equal(t, "one ***\n", out.String())
+	fmt.Fprint(w, "c x")
+	w.Flush()
+	assert.Equal(t, "one ***\ntwo *** x", out.String())
+}
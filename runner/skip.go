@@ -2,6 +2,7 @@ package runner
 
 import (
 	"os/exec"
+	"time"
 
 	"github.com/campbel/run/runfile"
 )
@@ -20,15 +21,79 @@ func NewSkipContext(actionContext *ActionContext, skip runfile.Skip) *SkipContex
 	}
 }
 
-func (ctx *SkipContext) Run(vars any) (bool, error) {
+func (ctx *SkipContext) Run(parent int, vars any) (bool, error) {
 	if ctx.Shell != "" {
 		subbedCommand, err := varSub(vars, ctx.Shell)
 		if err != nil {
 			return false, err
 		}
-		command := exec.Command("sh", "-c", subbedCommand)
-		command.Env = commandEnv(ctx.actionContext.Env())
-		return command.Run() == nil, nil
+		if !ctx.check(parent, subbedCommand) {
+			return false, nil
+		}
+		ctx.report(parent)
+		return true, nil
 	}
 	return false, nil
 }
+
+// check runs the skip command, the action is skipped when it succeeds.
+func (ctx *SkipContext) check(parent int, subbedCommand string) bool {
+	global := ctx.actionContext.Global
+	id := global.nextID()
+	start := time.Now()
+	global.emit(Event{
+		Type:    SkipStarted,
+		ID:      id,
+		Parent:  parent,
+		Time:    start,
+		Package: ctx.actionContext.Package.URI,
+		Action:  ctx.actionContext.FullName(),
+		Command: subbedCommand,
+	})
+
+	command := exec.Command("sh", "-c", subbedCommand)
+	command.Env = commandEnv(ctx.actionContext.Env())
+	if global.skipOutput {
+		stdout, stderr, flush := global.commandOutput(ctx.actionContext.FullName())
+		defer flush()
+		command.Stdout = stdout
+		command.Stderr = stderr
+	}
+	err := command.Run()
+
+	status := StatusSkipped
+	if err != nil {
+		status = StatusOK
+	}
+	global.emit(Event{
+		Type:     SkipFinished,
+		ID:       id,
+		Parent:   parent,
+		Time:     time.Now(),
+		Package:  ctx.actionContext.Package.URI,
+		Action:   ctx.actionContext.FullName(),
+		Command:  subbedCommand,
+		Status:   status,
+		Duration: time.Since(start),
+		ExitCode: exitCode(err),
+	})
+	return err == nil
+}
+
+func (ctx *SkipContext) report(parent int) {
+	global := ctx.actionContext.Global
+	global.emit(Event{
+		Type:    ActionSkipped,
+		ID:      parent,
+		Time:    time.Now(),
+		Package: ctx.actionContext.Package.URI,
+		Action:  ctx.actionContext.FullName(),
+		Message: ctx.Message,
+	})
+
+	if ctx.Message == "" {
+		global.fprintf(global.out, "[%s] skipped\n", ctx.actionContext.FullName())
+		return
+	}
+	global.fprintf(global.out, "[%s] skipped: %s\n", ctx.actionContext.FullName(), ctx.Message)
+}
@@ -0,0 +1,55 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkipContext_Report(t *testing.T) {
+	tests := []struct {
+		name       string
+		skip       runfile.Skip
+		skipOutput bool
+		expected   string
+	}{
+		{
+			name:     "with message",
+			skip:     runfile.Skip{Shell: "echo checking; true", Message: "go is installed"},
+			expected: "[install] skipped: go is installed\n",
+		},
+		{
+			name:     "without message",
+			skip:     runfile.Skip{Shell: "true"},
+			expected: "[install] skipped\n",
+		},
+		{
+			name:     "not skipped",
+			skip:     runfile.Skip{Shell: "echo checking; false", Message: "go is installed"},
+			expected: "installing\n",
+		},
+		{
+			name:       "debug skip output",
+			skip:       runfile.Skip{Shell: "echo checking; echo oops >&2; true", Message: "go is installed"},
+			skipOutput: true,
+			expected:   "checking\noops\n[install] skipped: go is installed\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			global := NewGlobalContext().WithStdout(&out).WithErrout(&out).WithSkipOutput(tt.skipOutput)
+			pkg := NewPackageContext(global, runfile.NewRunfile())
+			install := NewActionContext(global, pkg, "install", runfile.Action{
+				Skip:     tt.skip,
+				Commands: []runfile.Command{{Shell: "echo installing"}},
+			})
+
+			assert.NoError(t, install.Run(nil))
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package runner
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Summary is an Observer that collects every action of a run into a table.
+type Summary struct {
+	rows  []*summaryRow
+	index map[int]*summaryRow
+}
+
+type summaryRow struct {
+	depth    int
+	action   string
+	pkg      string
+	status   Status
+	duration time.Duration
+	exitCode int
+}
+
+func NewSummary() *Summary {
+	return &Summary{
+		index: make(map[int]*summaryRow),
+	}
+}
+
+func (s *Summary) Observe(event Event) {
+	switch event.Type {
+	case ActionStarted:
+		row := &summaryRow{action: event.Action, pkg: event.Package}
+		if parent, ok := s.index[event.Parent]; ok {
+			row.depth = parent.depth + 1
+		}
+		s.rows = append(s.rows, row)
+		s.index[event.ID] = row
+	case ActionFinished:
+		if row, ok := s.index[event.ID]; ok {
+			row.status = event.Status
+			row.duration = event.Duration
+			row.exitCode = event.ExitCode
+		}
+	}
+}
+
+// Print writes the table in the order the actions started, with nested
+// actions indented under the action that ran them.
+func (s *Summary) Print(w io.Writer) {
+	tabwriter := tabwriter.NewWriter(w, 0, 0, 1, ' ', 0)
+	fmt.Fprintln(tabwriter, "ACTION\tPACKAGE\tSTATUS\tDURATION\tEXIT")
+	for _, row := range s.rows {
+		pkg := row.pkg
+		if pkg == "" {
+			pkg = "main"
+		}
+		fmt.Fprintf(tabwriter, "%s%s\t%s\t%s\t%s\t%d\n",
+			strings.Repeat("  ", row.depth), row.action, pkg, row.status, row.duration.Round(time.Millisecond), row.exitCode)
+	}
+	tabwriter.Flush()
+}
@@ -0,0 +1,62 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSummary(t *testing.T) {
+	summary := NewSummary()
+	global := NewGlobalContext().WithStdout(&bytes.Buffer{}).WithObserver(summary)
+
+	tools := NewPackageContext(global, runfile.NewRunfile()).WithName("tools").WithURI("github.com/org/tools")
+	tools.Actions["install"] = NewActionContext(global, tools, "install", runfile.Action{
+		Skip: runfile.Skip{Shell: "true"},
+	})
+
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	pkg.Imports["tools"] = tools
+	pkg.Actions["build"] = NewActionContext(global, pkg, "build", runfile.Action{
+		Dependencies: []string{"tools.install"},
+		Commands:     []runfile.Command{{Shell: "exit 3"}},
+	})
+
+	assert.Error(t, pkg.Run("build", nil))
+
+	assert.Len(t, summary.rows, 2)
+	assert.Equal(t, summaryRow{action: "build", pkg: "", status: StatusFailed, exitCode: 3}, withoutDuration(summary.rows[0]))
+	assert.Equal(t, summaryRow{depth: 1, action: "tools.install", pkg: "github.com/org/tools", status: StatusSkipped}, withoutDuration(summary.rows[1]))
+}
+
+func TestSummary_Print(t *testing.T) {
+	summary := NewSummary()
+	for _, event := range []Event{
+		{Type: ActionStarted, ID: 1, Action: "build"},
+		{Type: ActionStarted, ID: 2, Parent: 1, Action: "tools.install", Package: "github.com/org/tools"},
+		{Type: ActionFinished, ID: 2, Status: StatusSkipped, Duration: 12 * time.Millisecond},
+		{Type: ActionStarted, ID: 3, Parent: 1, Action: "generate"},
+		{Type: ActionFinished, ID: 3, Status: StatusOK, Duration: 1500 * time.Millisecond},
+		{Type: ActionFinished, ID: 1, Status: StatusFailed, Duration: 2 * time.Second, ExitCode: 3},
+	} {
+		summary.Observe(event)
+	}
+
+	var out bytes.Buffer
+	summary.Print(&out)
+	assert.Equal(t, ""+
+		"ACTION          PACKAGE              STATUS  DURATION EXIT\n"+
+		"build           main                 failed  2s       3\n"+
+		"  tools.install github.com/org/tools skipped 12ms     0\n"+
+		"  generate      main                 ok      1.5s     0\n",
+		out.String())
+}
+
+func withoutDuration(row *summaryRow) summaryRow {
+	r := *row
+	r.duration = 0
+	return r
+}
@@ -0,0 +1,148 @@
+package runner
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// Trace is an Observer that records a run as a Chrome Trace Event Format
+// timeline. Nested steps share the lane of their parent, steps that overlap
+// with a sibling are placed on a lane of their own.
+type Trace struct {
+	start  time.Time
+	events []traceEvent
+	open   map[int]*traceSpan
+	lanes  [][]int
+}
+
+type traceSpan struct {
+	lane  int
+	start Event
+}
+
+type traceEvent struct {
+	Name      string         `json:"name"`
+	Category  string         `json:"cat"`
+	Phase     string         `json:"ph"`
+	Timestamp int64          `json:"ts"`
+	Duration  int64          `json:"dur"`
+	PID       int            `json:"pid"`
+	TID       int            `json:"tid"`
+	Args      map[string]any `json:"args,omitempty"`
+}
+
+func NewTrace() *Trace {
+	return &Trace{
+		open: make(map[int]*traceSpan),
+	}
+}
+
+func (t *Trace) Observe(event Event) {
+	switch event.Type {
+	case ActionStarted, CommandStarted, VarStarted, SkipStarted:
+		// Only vars that run a shell command take time worth showing
+		if event.Type == VarStarted && event.Command == "" {
+			return
+		}
+		if t.start.IsZero() {
+			t.start = event.Time
+		}
+		t.open[event.ID] = &traceSpan{lane: t.lane(event), start: event}
+	case ActionFinished, CommandExited, VarFinished, SkipFinished:
+		span, ok := t.open[event.ID]
+		if !ok {
+			return
+		}
+		delete(t.open, event.ID)
+		t.release(span.lane, event.ID)
+
+		name, category := traceName(span.start)
+		args := map[string]any{}
+		if event.Package != "" {
+			args["package"] = event.Package
+		}
+		if event.Command != "" {
+			args["command"] = event.Command
+		}
+		if event.Status != "" {
+			args["status"] = event.Status
+		}
+		if event.ExitCode != 0 {
+			args["exit_code"] = event.ExitCode
+		}
+		t.events = append(t.events, traceEvent{
+			Name:      name,
+			Category:  category,
+			Phase:     "X",
+			Timestamp: span.start.Time.Sub(t.start).Microseconds(),
+			Duration:  event.Duration.Microseconds(),
+			PID:       1,
+			TID:       span.lane,
+			Args:      args,
+		})
+	}
+}
+
+// Write encodes the trace, it can be opened in chrome://tracing or Perfetto.
+func (t *Trace) Write(w io.Writer) error {
+	events := t.events
+	if events == nil {
+		events = []traceEvent{}
+	}
+	return json.NewEncoder(w).Encode(map[string]any{
+		"traceEvents":     events,
+		"displayTimeUnit": "ms",
+	})
+}
+
+// lane picks the lane for a starting step: its parent's lane when the parent
+// is the innermost open step there, otherwise the first free lane.
+func (t *Trace) lane(event Event) int {
+	lane := -1
+	if parent, ok := t.open[event.Parent]; ok {
+		stack := t.lanes[parent.lane]
+		if stack[len(stack)-1] == event.Parent {
+			lane = parent.lane
+		}
+	}
+	if lane < 0 {
+		for i, stack := range t.lanes {
+			if len(stack) == 0 {
+				lane = i
+				break
+			}
+		}
+	}
+	if lane < 0 {
+		t.lanes = append(t.lanes, nil)
+		lane = len(t.lanes) - 1
+	}
+	t.lanes[lane] = append(t.lanes[lane], event.ID)
+	return lane
+}
+
+func (t *Trace) release(lane, id int) {
+	stack := t.lanes[lane]
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == id {
+			t.lanes[lane] = append(stack[:i], stack[i+1:]...)
+			return
+		}
+	}
+}
+
+func traceName(event Event) (string, string) {
+	switch event.Type {
+	case CommandStarted:
+		return event.Command, "command"
+	case VarStarted:
+		return event.Action + " var " + event.Var, "var"
+	case SkipStarted:
+		return event.Action + " skip", "skip"
+	}
+	if event.Dep {
+		return event.Action, "dep"
+	}
+	return event.Action, "action"
+}
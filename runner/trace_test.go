@@ -0,0 +1,42 @@
+package runner
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace_Lanes(t *testing.T) {
+	trace := NewTrace()
+	start := time.Now()
+	at := func(ms int) time.Time {
+		return start.Add(time.Duration(ms) * time.Millisecond)
+	}
+
+	for _, event := range []Event{
+		{Type: ActionStarted, ID: 1, Time: at(0), Action: "build"},
+		{Type: ActionStarted, ID: 2, Parent: 1, Time: at(1), Action: "lint", Dep: true},
+		{Type: ActionStarted, ID: 3, Parent: 1, Time: at(2), Action: "test", Dep: true},
+		{Type: CommandStarted, ID: 4, Parent: 3, Time: at(3), Command: "go test"},
+		{Type: ActionFinished, ID: 2, Parent: 1, Time: at(4), Duration: 3 * time.Millisecond},
+		{Type: CommandExited, ID: 4, Parent: 3, Time: at(5), Duration: 2 * time.Millisecond},
+		{Type: ActionFinished, ID: 3, Parent: 1, Time: at(6), Duration: 4 * time.Millisecond},
+		{Type: CommandStarted, ID: 5, Parent: 1, Time: at(7), Command: "go build"},
+		{Type: CommandExited, ID: 5, Parent: 1, Time: at(8), Duration: time.Millisecond},
+		{Type: ActionFinished, ID: 1, Time: at(9), Duration: 9 * time.Millisecond},
+	} {
+		trace.Observe(event)
+	}
+
+	lanes := map[string]int{}
+	categories := map[string]string{}
+	for _, event := range trace.events {
+		lanes[event.Name] = event.TID
+		categories[event.Name] = event.Category
+	}
+	assert.Equal(t, map[string]int{"build": 0, "lint": 0, "test": 1, "go test": 1, "go build": 0}, lanes)
+	assert.Equal(t, "dep", categories["lint"])
+	assert.Equal(t, "action", categories["build"])
+	assert.Equal(t, int64(1000), trace.events[0].Timestamp)
+}
@@ -0,0 +1,58 @@
+package runner
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// ReadValues reads YAML or JSON values files and merges them in order, later
+// files overriding earlier ones. Nested maps are merged key by key.
+func ReadValues(paths ...string) (map[string]any, error) {
+	values := make(map[string]any)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read values file")
+		}
+		var fileValues map[string]any
+		if err := yaml.Unmarshal(data, &fileValues); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal values file %s", path)
+		}
+		mergeValues(values, fileValues)
+	}
+	return values, nil
+}
+
+func mergeValues(dst, src map[string]any) {
+	for key, value := range src {
+		srcMap, srcIsMap := value.(map[string]any)
+		dstMap, dstIsMap := dst[key].(map[string]any)
+		if srcIsMap && dstIsMap {
+			mergeValues(dstMap, srcMap)
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// ValuesArgs returns the top level scalar values as args.
+func ValuesArgs(values map[string]any) map[string]string {
+	args := make(map[string]string)
+	for key, value := range values {
+		switch value.(type) {
+		case map[string]any, []any, nil:
+			continue
+		}
+		args[key] = fmt.Sprint(value)
+	}
+	return args
+}
+
+// WithValues makes the values available to templates as .VALUES.
+func (c *GlobalContext) WithValues(values map[string]any) *GlobalContext {
+	c.values = values
+	return c
+}
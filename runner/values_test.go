@@ -0,0 +1,35 @@
+package runner
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValues(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "values.yaml")
+	override := filepath.Join(dir, "prod.json")
+	assert.NoError(t, os.WriteFile(base, []byte("ENV: staging\ndb:\n  host: localhost\n  port: 5432\n"), 0644))
+	assert.NoError(t, os.WriteFile(override, []byte(`{"ENV": "prod", "db": {"host": "db.prod"}, "REPLICAS": 3}`), 0644))
+
+	values, err := ReadValues(base, override)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"ENV": "prod", "REPLICAS": "3"}, ValuesArgs(values))
+
+	var out bytes.Buffer
+	global := NewGlobalContext().WithStdout(&out).WithValues(values)
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	pkg.Actions["deploy"] = NewActionContext(global, pkg, "deploy", runfile.Action{
+		Commands: []runfile.Command{
+			{Shell: "echo {{ .ARGS.ENV }} {{ .VALUES.db.host }}:{{ .VALUES.db.port }}"},
+		},
+	})
+
+	assert.NoError(t, pkg.Run("deploy", ValuesArgs(values)))
+	assert.Equal(t, "prod db.prod:5432\n", out.String())
+}
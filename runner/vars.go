@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"os/exec"
 	"strings"
+	"time"
 
 	"github.com/campbel/run/runfile"
 	"github.com/pkg/errors"
@@ -11,40 +12,114 @@ import (
 
 type VarContext struct {
 	actionContext *ActionContext
+	Name          string
 	Value         string
 	Shell         string
+	File          string
+	Env           string
+	Secret        bool
+	Prompt        string
+	Choices       []string
 }
 
 func NewVarContexts(actionContext *ActionContext, vars map[string]runfile.Var) map[string]*VarContext {
 	contexts := make(map[string]*VarContext)
 	for name, varCtx := range vars {
-		contexts[name] = NewVarContext(actionContext, varCtx)
+		contexts[name] = NewVarContext(actionContext, name, varCtx)
 	}
 	return contexts
 }
 
-func NewVarContext(actionContex *ActionContext, varCtx runfile.Var) *VarContext {
+func NewVarContext(actionContex *ActionContext, name string, varCtx runfile.Var) *VarContext {
 	return &VarContext{
 		actionContext: actionContex,
+		Name:          name,
 		Value:         varCtx.Value,
 		Shell:         varCtx.Shell,
+		File:          varCtx.File,
+		Env:           varCtx.Env,
+		Secret:        varCtx.Secret,
+		Prompt:        varCtx.Prompt,
+		Choices:       varCtx.Choices,
 	}
 }
 
-func (ctx *VarContext) GetValue(args any) (any, error) {
+func (ctx *VarContext) GetValue(parent int, args any) (any, error) {
+	var shellCmd string
 	if ctx.Shell != "" {
-		shellCmd, error := varSub(args, ctx.Shell)
-		if error != nil {
-			return nil, errors.Wrap(error, "failed to substitute shell command")
+		var err error
+		if shellCmd, err = varSub(args, ctx.Shell); err != nil {
+			return nil, errors.Wrap(err, "failed to substitute shell command")
 		}
+	}
+
+	global := ctx.actionContext.Global
+	id := global.nextID()
+	start := time.Now()
+	global.emit(Event{
+		Type:    VarStarted,
+		ID:      id,
+		Parent:  parent,
+		Time:    start,
+		Package: ctx.actionContext.Package.URI,
+		Action:  ctx.actionContext.FullName(),
+		Var:     ctx.Name,
+		Command: shellCmd,
+	})
+
+	value, err := ctx.resolve(shellCmd)
+	logged := value
+	if ctx.Secret {
+		global.secrets.add(value)
+		logged = mask
+	}
+
+	status := StatusOK
+	if err != nil {
+		status = StatusFailed
+	}
+	global.emit(Event{
+		Type:     VarFinished,
+		ID:       id,
+		Parent:   parent,
+		Time:     time.Now(),
+		Package:  ctx.actionContext.Package.URI,
+		Action:   ctx.actionContext.FullName(),
+		Var:      ctx.Name,
+		Value:    logged,
+		Command:  shellCmd,
+		Status:   status,
+		Duration: time.Since(start),
+		ExitCode: exitCode(err),
+		Err:      err,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// promptOnly reports whether the value of the var is only ever prompted for.
+func (ctx *VarContext) promptOnly() bool {
+	return ctx.Prompt != "" && ctx.Value == "" && ctx.Shell == "" && ctx.File == "" && ctx.Env == ""
+}
+
+func (ctx *VarContext) resolve(shellCmd string) (string, error) {
+	if value, ok, err := readSource(ctx.File, ctx.Env); ok {
+		return value, err
+	}
+	if shellCmd != "" {
 		command := exec.Command("sh", "-c", shellCmd)
 		command.Env = commandEnv(ctx.actionContext.Env())
 		var buffer bytes.Buffer
 		command.Stdout = &buffer
 		if err := command.Run(); err != nil {
-			return nil, errors.Wrap(err, "failed to run shell command")
+			return "", errors.Wrap(err, "failed to run shell command")
 		}
 		return strings.TrimSpace(buffer.String()), nil
 	}
+	if ctx.Value == "" && ctx.Prompt != "" {
+		return ctx.actionContext.Global.prompt(ctx.Prompt, ctx.Choices)
+	}
 	return ctx.Value, nil
 }
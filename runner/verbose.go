@@ -0,0 +1,53 @@
+package runner
+
+import "fmt"
+
+// WithVerbose echoes every step of a run to stderr: rendered commands, resolved
+// vars, skip checks and the package each action comes from.
+func (c *GlobalContext) WithVerbose(verbose bool) *GlobalContext {
+	if verbose {
+		c.observers = append(c.observers, &verboseLogger{global: c})
+	}
+	return c
+}
+
+type verboseLogger struct {
+	global *GlobalContext
+}
+
+func (l *verboseLogger) Observe(event Event) {
+	switch event.Type {
+	case ActionStarted:
+		if event.Package == "" {
+			l.printf("==> %s\n", event.Action)
+		} else {
+			l.printf("==> %s (%s)\n", event.Action, event.Package)
+		}
+	case VarStarted:
+		if event.Command != "" {
+			l.printf("+ [%s] var %s: %s\n", event.Action, event.Var, event.Command)
+		}
+	case VarFinished:
+		if event.Err != nil {
+			return
+		}
+		// Secrets are masked before events are observed
+		l.printf("  [%s] VARS.%s=%s\n", event.Action, event.Var, event.Value)
+	case SkipStarted:
+		l.printf("+ [%s] skip check: %s\n", event.Action, event.Command)
+	case SkipFinished:
+		if event.Status == StatusSkipped {
+			l.printf("  [%s] skip check passed, skipping\n", event.Action)
+		} else {
+			l.printf("  [%s] skip check exited %d, running\n", event.Action, event.ExitCode)
+		}
+	case CommandStarted:
+		l.printf("+ [%s] %s\n", event.Action, event.Command)
+	}
+}
+
+func (l *verboseLogger) printf(format string, args ...any) {
+	l.global.writeMu.Lock()
+	defer l.global.writeMu.Unlock()
+	fmt.Fprintf(l.global.err, format, args...)
+}
@@ -0,0 +1,43 @@
+package runner
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/campbel/run/runfile"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerbose(t *testing.T) {
+	t.Setenv("DB_PASS", "hunter2")
+
+	var out, log, stream bytes.Buffer
+	global := NewGlobalContext().
+		WithStdout(&out).
+		WithErrout(&log).
+		WithVerbose(true).
+		WithObserver(NewJSONEvents(&stream))
+	pkg := NewPackageContext(global, runfile.NewRunfile())
+	connect := NewActionContext(global, pkg, "connect", runfile.Action{
+		Vars: map[string]runfile.Var{
+			"db":   {Env: "DB_PASS", Secret: true},
+			"HOST": {Shell: "echo localhost"},
+		},
+		Skip:     runfile.Skip{Shell: "test {{ .VARS.db }} = skip"},
+		Commands: []runfile.Command{{Shell: "echo {{ .VARS.HOST }}:{{ .VARS.db }}"}},
+	})
+
+	assert.NoError(t, connect.Run(nil))
+	assert.Equal(t, "localhost:***\n", out.String())
+	assert.Equal(t, ""+
+		"==> connect\n"+
+		"+ [connect] var HOST: echo localhost\n"+
+		"  [connect] VARS.HOST=localhost\n"+
+		"  [connect] VARS.db=***\n"+
+		"+ [connect] skip check: test *** = skip\n"+
+		"  [connect] skip check exited 1, running\n"+
+		"+ [connect] echo localhost:***\n",
+		log.String())
+	assert.NotContains(t, stream.String(), "hunter2")
+	assert.NotContains(t, stream.String(), `"value"`)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+
+	"github.com/campbel/run/runner"
+	"github.com/pkg/errors"
+)
+
+type SecretsCommands struct {
+	Encrypt func(EncryptOptions) error
+	Decrypt func(DecryptOptions) error
+}
+
+type EncryptOptions struct {
+	File   string `yoshi:"FILE;The YAML secrets file to encrypt"`
+	Output string `yoshi:"--output,-o;The file to write, defaults to FILE.enc"`
+}
+
+type DecryptOptions struct {
+	File string `yoshi:"FILE;The encrypted secrets file to print"`
+}
+
+var secretsCommands = SecretsCommands{
+	Encrypt: func(options EncryptOptions) error {
+		data, err := os.ReadFile(options.File)
+		if err != nil {
+			return errors.Wrap(err, "failed to read secrets file")
+		}
+		passphrase, err := runner.NewGlobalContext().SecretsPassphrase(true)
+		if err != nil {
+			return err
+		}
+		encrypted, err := runner.EncryptSecrets(passphrase, data)
+		if err != nil {
+			return errors.Wrap(err, "failed to encrypt secrets")
+		}
+		output := options.Output
+		if output == "" {
+			output = options.File + ".enc"
+		}
+		return os.WriteFile(output, encrypted, 0600)
+	},
+	Decrypt: func(options DecryptOptions) error {
+		data, err := os.ReadFile(options.File)
+		if err != nil {
+			return errors.Wrap(err, "failed to read secrets file")
+		}
+		passphrase, err := runner.NewGlobalContext().SecretsPassphrase(false)
+		if err != nil {
+			return err
+		}
+		decrypted, err := runner.DecryptSecrets(passphrase, data)
+		if err != nil {
+			return errors.Wrap(err, "failed to decrypt secrets")
+		}
+		_, err = os.Stdout.Write(decrypted)
+		return err
+	},
+}
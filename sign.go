@@ -0,0 +1,137 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/campbel/run/loader"
+	"github.com/pkg/errors"
+)
+
+type SignCommands struct {
+	Keygen  func(KeygenOptions) error
+	Package func(SignPackageOptions) error
+	Digest  func(SignDigestOptions) error
+}
+
+type KeygenOptions struct {
+	Output string `yoshi:"--output,-o;The private key file to write, run/signing.key in the user config dir when unset"`
+}
+
+type SignPackageOptions struct {
+	Dir string `yoshi:"DIR;The directory of the package to sign;."`
+	Key string `yoshi:"--key,-k;The private key file to sign with, run/signing.key in the user config dir when unset"`
+}
+
+type SignDigestOptions struct {
+	Dir    string `yoshi:"DIR;The directory of the package to sign;."`
+	Output string `yoshi:"--output,-o;The file to write the message to sign with minisign, stdout when unset"`
+}
+
+var signCommands = SignCommands{
+	Keygen: func(options KeygenOptions) error {
+		path, err := keyPath(options.Output)
+		if err != nil {
+			return err
+		}
+		return keygen(os.Stdout, path)
+	},
+	Package: func(options SignPackageOptions) error {
+		path, err := keyPath(options.Key)
+		if err != nil {
+			return err
+		}
+		return signPackage(options.Dir, path)
+	},
+	Digest: func(options SignDigestOptions) error {
+		return writeDigest(os.Stdout, options.Dir, options.Output)
+	},
+}
+
+// keyPath returns the path of the private key, by default in the user config
+// dir so it is never inside a package.
+func keyPath(path string) (string, error) {
+	if path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", errors.Wrap(err, "failed to find the user config dir")
+	}
+	return filepath.Join(dir, "run", "signing.key"), nil
+}
+
+// keygen writes a new private key to path and prints its public key.
+func keygen(w io.Writer, path string) error {
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("%s already exists", path)
+	}
+	publicKey, privateKey, err := loader.GenerateKey()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return errors.Wrap(err, "failed to create private key dir")
+	}
+	if err := os.WriteFile(path, privateKey, 0600); err != nil {
+		return errors.Wrap(err, "failed to write private key")
+	}
+	fmt.Fprintln(w, publicKey)
+	return nil
+}
+
+// signPackage writes the run.sig of the package in dir with the private key
+// at keyPath.
+func signPackage(dir, keyPath string) error {
+	// The key would be signed and shipped with the package
+	if inside(dir, keyPath) {
+		return fmt.Errorf("the private key %s must not be inside the package", keyPath)
+	}
+	data, err := os.ReadFile(keyPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read private key")
+	}
+	key, err := loader.ParsePrivateKey(data)
+	if err != nil {
+		return err
+	}
+	return loader.SignPackage(dir, key)
+}
+
+// writeDigest writes the message a signature of the package in dir signs to
+// path, or w when no path is given, for packages signed with minisign.
+func writeDigest(w io.Writer, dir, path string) error {
+	// The digest would change the package it is the digest of
+	if path != "" && inside(dir, path) {
+		return fmt.Errorf("the digest %s must not be inside the package", path)
+	}
+	message, err := loader.SignedMessage(dir)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		_, err := w.Write(message)
+		return errors.Wrap(err, "failed to write digest")
+	}
+	return errors.Wrap(os.WriteFile(path, message, 0644), "failed to write digest")
+}
+
+// inside reports whether path is inside dir.
+func inside(dir, path string) bool {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return false
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(absDir, absPath)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
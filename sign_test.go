@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/campbel/run/loader"
+	"github.com/campbel/yoshi"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v3"
+)
+
+func TestSignCommands(t *testing.T) {
+	dir := t.TempDir()
+	pkg := filepath.Join(dir, "pkg")
+	assert.NoError(t, os.MkdirAll(pkg, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(pkg, "run.yaml"), []byte("actions: {}"), 0644))
+
+	// Keys next to the package may start with ..
+	keyPath := filepath.Join(dir, "..key")
+	var out bytes.Buffer
+	assert.NoError(t, keygen(&out, keyPath))
+	info, err := os.Stat(keyPath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	publicKey := strings.TrimSpace(out.String())
+	_, err = loader.ParsePublicKey(publicKey)
+	assert.NoError(t, err)
+	assert.EqualError(t, keygen(&out, keyPath), keyPath+" already exists")
+
+	assert.NoError(t, signPackage(pkg, keyPath))
+	data, err := os.ReadFile(filepath.Join(pkg, "run.sig"))
+	assert.NoError(t, err)
+	var signature loader.Signature
+	assert.NoError(t, yaml.Unmarshal(data, &signature))
+	assert.Equal(t, publicKey, signature.Key)
+
+	innerKey := filepath.Join(pkg, "keys", "run-signing.key")
+	assert.EqualError(t, signPackage(pkg, innerKey), "the private key "+innerKey+" must not be inside the package")
+	assert.EqualError(t, writeDigest(&out, pkg, filepath.Join(pkg, "run.digest")), "the digest "+filepath.Join(pkg, "run.digest")+" must not be inside the package")
+
+	digest := filepath.Join(dir, "run.digest")
+	assert.NoError(t, writeDigest(&out, pkg, digest))
+	message, err := loader.SignedMessage(pkg)
+	assert.NoError(t, err)
+	data, err = os.ReadFile(digest)
+	assert.NoError(t, err)
+	assert.Equal(t, message, data)
+}
+
+func TestSignCommands_Defaults(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", config)
+	t.Setenv("HOME", config)
+
+	pkg := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(pkg, "run.yaml"), []byte("actions: {}"), 0644))
+	wd, err := os.Getwd()
+	assert.NoError(t, err)
+	assert.NoError(t, os.Chdir(pkg))
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	var help bytes.Buffer
+	sign := yoshi.New("run sign").WithConfig(yoshi.Config{HelpWriter: &help})
+	assert.NoError(t, sign.RunWithArgs(signCommands, "keygen"))
+	assert.FileExists(t, filepath.Join(config, "run", "signing.key"))
+	assert.NoError(t, sign.RunWithArgs(signCommands, "package"))
+	assert.FileExists(t, filepath.Join(pkg, "run.sig"))
+	assert.NoFileExists(t, filepath.Join(pkg, "signing.key"))
+	assert.NoError(t, sign.RunWithArgs(signCommands, "digest"))
+	assert.Empty(t, help.String())
+
+	var out bytes.Buffer
+	assert.NoError(t, writeDigest(&out, ".", ""))
+	message, err := loader.SignedMessage(pkg)
+	assert.NoError(t, err)
+	assert.Equal(t, string(message), out.String())
+}
+
+func TestInside(t *testing.T) {
+	dir := t.TempDir()
+	assert.True(t, inside(dir, dir))
+	assert.True(t, inside(dir, filepath.Join(dir, "run-signing.key")))
+	assert.True(t, inside(dir, filepath.Join(dir, "..key", "run-signing.key")))
+	assert.False(t, inside(dir, filepath.Join(dir, "..")))
+	assert.False(t, inside(dir, filepath.Join(dir, "..", "run-signing.key")))
+	assert.False(t, inside(filepath.Join(dir, "pkg"), filepath.Join(dir, "..key")))
+}